@@ -0,0 +1,255 @@
+package gorgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cast"
+)
+
+// Accumulator - value computed over the records of each group, see GroupQuery
+type Accumulator struct {
+	op    string
+	field string
+	alias string
+}
+
+// Aggregation - groups of the records of a query. A group field comes back
+// under its name, dots replaced by "_", and each accumulator under its
+// alias, the names Having and the order of the session refer to.
+type Aggregation struct {
+	GroupBy      []string
+	Accumulators []Accumulator
+	Having       Cond
+}
+
+// AggregateDialect - dialect computing the groups in the database. q.Where
+// and q.Cond select the records, q.Order, q.Offset and q.Limit apply to the
+// groups. The other dialects are grouped in process, streaming the records.
+type AggregateDialect interface {
+	Aggregate(tableName string, q Query, agg Aggregation) ([]JSONDoc, error)
+}
+
+// GroupQuery - aggregation of the records of a session, ex:
+//
+//	db.Table("sale").Where(Gte("date", from)).OrderBy("-total").
+//		GroupBy("city").Sum("amount", "total").Count().Having(Gt("count", 10)).Get()
+//
+// returns documents like {"city": "Rio", "total": 1520.5, "count": 34}.
+type GroupQuery struct {
+	s   *Session
+	agg Aggregation
+}
+
+// GroupBy - group the records having the same values of the fields, no field
+// makes a single group of all the records
+func (s *Session) GroupBy(fields ...string) *GroupQuery {
+	return &GroupQuery{s: s, agg: Aggregation{GroupBy: fields}}
+}
+
+func (g *GroupQuery) add(op string, field string, alias []string) *GroupQuery {
+	name := strings.TrimPrefix(op, "$")
+	if field != "" {
+		name += "_" + groupName(field)
+	}
+	if len(alias) > 0 {
+		name = alias[0]
+	}
+	g.agg.Accumulators = append(g.agg.Accumulators, Accumulator{op: op, field: field, alias: name})
+	return g
+}
+
+// Sum - sum of the field, named sum_<field> or alias
+func (g *GroupQuery) Sum(field string, alias ...string) *GroupQuery {
+	return g.add("$sum", field, alias)
+}
+
+// Avg - average of the non null values of the field, named avg_<field> or alias
+func (g *GroupQuery) Avg(field string, alias ...string) *GroupQuery {
+	return g.add("$avg", field, alias)
+}
+
+// Min - lowest value of the field, named min_<field> or alias
+func (g *GroupQuery) Min(field string, alias ...string) *GroupQuery {
+	return g.add("$min", field, alias)
+}
+
+// Max - highest value of the field, named max_<field> or alias
+func (g *GroupQuery) Max(field string, alias ...string) *GroupQuery {
+	return g.add("$max", field, alias)
+}
+
+// Count - records of the group, named count or alias
+func (g *GroupQuery) Count(alias ...string) *GroupQuery {
+	return g.add("$count", "", alias)
+}
+
+// Having - keep the groups matching the condition on the group fields and
+// the accumulators
+func (g *GroupQuery) Having(cond Cond) *GroupQuery {
+	g.agg.Having = cond
+	return g
+}
+
+// OrderBy - sort the groups, ex: "-total, city"
+func (g *GroupQuery) OrderBy(order string) *GroupQuery {
+	g.s.OrderBy(order)
+	return g
+}
+
+// Limit - return at most i groups, 0 for all of them
+func (g *GroupQuery) Limit(i int) *GroupQuery {
+	g.s.Limit(i)
+	return g
+}
+
+// Offset - skip the first i groups
+func (g *GroupQuery) Offset(i int) *GroupQuery {
+	g.s.Offset(i)
+	return g
+}
+
+// Get - the groups
+func (g *GroupQuery) Get() ([]JSONDoc, error) {
+	s := g.s
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	if len(g.agg.GroupBy) == 0 && len(g.agg.Accumulators) == 0 {
+		return nil, fmt.Errorf("GroupBy needs fields or accumulators")
+	}
+	q := s.query()
+	q.Columns = nil
+	q.Offset, q.Limit = s.offset, s.limit
+	if ad, ok := s.orm.dialectDB.(AggregateDialect); ok {
+		return ad.Aggregate(s.tableName, q, g.agg)
+	}
+
+	rs := *s
+	rs.order, rs.offset, rs.limit, rs.columns = "", 0, 0, nil
+	gr := newGrouper(g.agg)
+	err := rs.Iterate(gr.add)
+	if err != nil {
+		return nil, err
+	}
+	return finishGroups(gr.groups(), g.agg, q)
+}
+
+// groupName - name of a group field in the result
+func groupName(field string) string {
+	return strings.Replace(field, ".", "_", -1)
+}
+
+// finishGroups - the groups matching Having, sorted and paged by the query
+func finishGroups(groups []JSONDoc, agg Aggregation, q Query) ([]JSONDoc, error) {
+	if !agg.Having.empty() {
+		filter := agg.Having.filter()
+		var kept []JSONDoc
+		for _, group := range groups {
+			ok, err := matchFilter(group, filter)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				kept = append(kept, group)
+			}
+		}
+		groups = kept
+	}
+	sortDocs(groups, q.Order)
+	if groups == nil {
+		groups = []JSONDoc{}
+	}
+	return pageDocs(groups, q.Offset, q.Limit), nil
+}
+
+// grouper - accumulators of the groups computed one record at a time
+type grouper struct {
+	agg   Aggregation
+	keys  []string
+	state map[string]*groupState
+}
+
+type groupState struct {
+	doc   JSONDoc
+	count int
+	sums  []float64
+	seen  []int
+	best  []interface{}
+}
+
+func newGrouper(agg Aggregation) *grouper {
+	return &grouper{agg: agg, state: map[string]*groupState{}}
+}
+
+func (gr *grouper) add(doc JSONDoc) error {
+	var values []interface{}
+	for _, field := range gr.agg.GroupBy {
+		v, _ := lookupField(doc, field)
+		values = append(values, v)
+	}
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	key := string(encoded)
+	st, ok := gr.state[key]
+	if !ok {
+		n := len(gr.agg.Accumulators)
+		st = &groupState{doc: JSONDoc{}, sums: make([]float64, n), seen: make([]int, n), best: make([]interface{}, n)}
+		for i, field := range gr.agg.GroupBy {
+			st.doc[groupName(field)] = values[i]
+		}
+		gr.state[key] = st
+		gr.keys = append(gr.keys, key)
+	}
+
+	st.count++
+	for i, acc := range gr.agg.Accumulators {
+		if acc.field == "" {
+			continue
+		}
+		v, _ := lookupField(doc, acc.field)
+		if v == nil {
+			continue
+		}
+		st.seen[i]++
+		switch acc.op {
+		case "$sum", "$avg":
+			st.sums[i] += cast.ToFloat64(v)
+		case "$min", "$max":
+			c, _ := compareValues(v, st.best[i])
+			if st.best[i] == nil || acc.op == "$min" && c < 0 || acc.op == "$max" && c > 0 {
+				st.best[i] = v
+			}
+		}
+	}
+	return nil
+}
+
+// groups - the groups in the order of their first record
+func (gr *grouper) groups() []JSONDoc {
+	var list []JSONDoc
+	for _, key := range gr.keys {
+		st := gr.state[key]
+		for i, acc := range gr.agg.Accumulators {
+			switch acc.op {
+			case "$count":
+				st.doc[acc.alias] = st.count
+			case "$sum":
+				st.doc[acc.alias] = st.sums[i]
+			case "$avg":
+				if st.seen[i] > 0 {
+					st.doc[acc.alias] = st.sums[i] / float64(st.seen[i])
+				} else {
+					st.doc[acc.alias] = nil
+				}
+			default:
+				st.doc[acc.alias] = st.best[i]
+			}
+		}
+		list = append(list, st.doc)
+	}
+	return list
+}
@@ -0,0 +1,199 @@
+package gorgo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RowError - error of one document of a bulk write, Index is its position in the list
+type RowError struct {
+	Index int
+	Err   error
+}
+
+// BulkError - errors of the documents of a bulk write, by index
+type BulkError []RowError
+
+func (e BulkError) Error() string {
+	var msgs []string
+	for _, row := range e {
+		msgs = append(msgs, fmt.Sprintf("row %d: %v", row.Index, row.Err))
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// BulkDialect - dialect inserting many documents in one batched write.
+// CreateMany sets the generated ids on the documents.
+type BulkDialect interface {
+	CreateMany(tableName string, docs []JSONDoc) error
+}
+
+// UpsertDialect - dialect inserting or updating a document in one write.
+// Keys identify the record, onInsert holds the fields only set by an insert.
+// Returns the stored document.
+type UpsertDialect interface {
+	Upsert(tableName string, doc JSONDoc, keys []string, onInsert JSONDoc) (JSONDoc, error)
+}
+
+// InsertMany - insert the documents in one batched write, the generated ids
+// are set on them once they are all written. The model validation of every
+// document runs first and the invalid ones are reported by index in a
+// BulkError, nothing is written then. With insert hooks the documents are
+// inserted one by one in a transaction, stopping at the first error. Mongo,
+// without transactions, keeps the documents written before a failing one.
+func (s *Session) InsertMany(docs []JSONDoc) error {
+	if s.tableName == "" {
+		return fmt.Errorf("need to set a tablename")
+	}
+	if len(docs) == 0 {
+		return nil
+	}
+	rows := make([]JSONDoc, len(docs))
+	for i, doc := range docs {
+		rows[i] = copyDoc(doc)
+		s.stamp(rows[i], true)
+	}
+	err := s.insertRows(rows)
+	if err != nil {
+		return err
+	}
+	pk := s.orm.primaryKey(s.tableName)
+	for i, doc := range docs {
+		doc[pk] = rows[i][pk]
+	}
+	return nil
+}
+
+// insertRows - insert the stamped copies of the documents of InsertMany
+func (s *Session) insertRows(rows []JSONDoc) error {
+	bd, ok := s.orm.dialectDB.(BulkDialect)
+	if ok && !s.orm.hooks.has(s.tableName, beforeInsert, afterInsert) {
+		return bd.CreateMany(s.tableName, rows)
+	}
+	return s.atomic(func(s *Session) error {
+		for i, doc := range rows {
+			err := s.orm.hooks.run(s, beforeInsert, doc)
+			if err == nil {
+				_, err = s.orm.dialectDB.Create(s.tableName, doc)
+			}
+			if err == nil {
+				err = s.orm.hooks.run(s, afterInsert, doc)
+			}
+			if err != nil {
+				return BulkError{{Index: i, Err: err}}
+			}
+		}
+		return nil
+	})
+}
+
+// Upsert - insert the document, or update the record having the same values
+// for the key fields, the primary key by default. A key must be the primary
+// key or a unique field of the model. The fields missing from doc keep their
+// stored value, the version of a versioned table is incremented and not
+// checked. Returns the stored document.
+func (s *Session) Upsert(doc JSONDoc, keyFields ...string) (JSONDoc, error) {
+	if s.tableName == "" {
+		return nil, fmt.Errorf("need to set a tablename")
+	}
+	doc = copyDoc(doc)
+	pk := s.orm.primaryKey(s.tableName)
+	if len(keyFields) == 0 {
+		keyFields = []string{pk}
+	}
+	for _, key := range keyFields {
+		if key != pk && !s.uniqueField(key) {
+			return nil, fmt.Errorf("Upsert key %s is not the primary key or a unique field of %s", key, s.tableName)
+		}
+		if doc[key] == nil {
+			if key == pk && len(keyFields) == 1 {
+				return s.Insert(doc)
+			}
+			return nil, fmt.Errorf("Upsert key %s has no value", key)
+		}
+	}
+	delete(doc, versionField)
+	onInsert := JSONDoc{}
+	s.stamp(onInsert, true)
+	s.stamp(doc, false)
+	for k := range doc {
+		delete(onInsert, k)
+	}
+
+	ud, ok := s.orm.dialectDB.(UpsertDialect)
+	if ok && !s.orm.hooks.has(s.tableName, beforeInsert, afterInsert, beforeUpdate, afterUpdate) {
+		return ud.Upsert(s.tableName, doc, keyFields, onInsert)
+	}
+	var stored JSONDoc
+	err := s.atomic(func(s *Session) error {
+		var err error
+		stored, err = upsertDoc(s.orm.dialectDB, s.tableName, doc, keyFields, onInsert, func(doc JSONDoc, insert bool) (JSONDoc, error) {
+			if insert {
+				return s.Insert(doc)
+			}
+			return doc, s.Update(doc)
+		})
+		return err
+	})
+	return stored, err
+}
+
+// uniqueField - true when the model declares the field unique
+func (s *Session) uniqueField(name string) bool {
+	md, ok := s.orm.dialectDB.(modelDialect)
+	if !ok {
+		return false
+	}
+	for _, f := range md.dbModel().Tables[s.tableName].Fields {
+		if f.Name == name {
+			return f.Unique
+		}
+	}
+	return false
+}
+
+// finder - the reads of a dialect upsertDoc needs
+type finder interface {
+	GetManyByQuery(string, Query) ([]JSONDoc, error)
+}
+
+// upsertDoc - read the record matching the keys and write doc merged into
+// it, or doc and onInsert when there is none, with write. Run it in a
+// transaction.
+func upsertDoc(d finder, tableName string, doc JSONDoc, keys []string, onInsert JSONDoc, write func(doc JSONDoc, insert bool) (JSONDoc, error)) (JSONDoc, error) {
+	var conds []Cond
+	for _, key := range keys {
+		conds = append(conds, Eq(key, doc[key]))
+	}
+	list, err := d.GetManyByQuery(tableName, Query{Cond: And(conds...)})
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		newDoc := copyDoc(doc)
+		for k, v := range onInsert {
+			newDoc[k] = v
+		}
+		return write(newDoc, true)
+	}
+	stored := list[0]
+	for k, v := range doc {
+		stored[k] = v
+	}
+	return write(stored, false)
+}
+
+// validateRows - validate every document, the errors are reported by index
+func validateRows(collection string, docs []JSONDoc, mod *model, funcs FuncMap) error {
+	var errs BulkError
+	for i, doc := range docs {
+		err := validateFields(collection, doc, mod, funcs)
+		if err != nil {
+			errs = append(errs, RowError{Index: i, Err: err})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package gorgo
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cast"
+)
+
+func TestSession_InsertManyUpsert(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	modelFile := filepath.Join(dir, "model.json")
+	err = ioutil.WriteFile(modelFile, []byte(sqliteTestModel), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dialect := range []string{"memory", "localdb", "sqlite"} {
+		config := ConfigDB{}
+		config.ModelFile = modelFile
+		config.Type = dialect
+		config.SyncDB = SyncCreate
+		config.Server = filepath.Join(dir, dialect+".db")
+		DB, err := NewOrm(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pk := DB.primaryKey("user")
+
+		docs := []JSONDoc{
+			{"name": "john", "email": "john@test.com", "age": 20},
+			{"name": "mary", "email": "mary@test.com", "age": 30},
+			{"name": "paul", "email": "paul@test.com"},
+		}
+		err = DB.Table("user").InsertMany(docs)
+		if err != nil {
+			t.Fatal(dialect, " InsertMany Error : ", err)
+		}
+		for _, doc := range docs {
+			stored, err := DB.Table("user").GetByID(fmt.Sprint(doc[pk]))
+			if err != nil || stored["email"] != doc["email"] {
+				t.Fatal(dialect, " InsertMany id not set : ", doc, stored, err)
+			}
+		}
+
+		invalid := []JSONDoc{
+			{"name": "anna", "email": "anna@test.com"},
+			{"name": "x", "email": "x@test.com"},
+			{"name": "bob", "email": "not an email"},
+		}
+		err = DB.Table("user").InsertMany(invalid)
+		bulkErr, ok := err.(BulkError)
+		if !ok || len(bulkErr) != 2 || bulkErr[0].Index != 1 || bulkErr[1].Index != 2 {
+			t.Fatal(dialect, " expected validation errors of rows 1 and 2 : ", err)
+		}
+		if len(invalid[0]) != 2 {
+			t.Fatal(dialect, " InsertMany changed the documents of a failed write : ", invalid[0])
+		}
+		i, err := DB.Table("user").Count()
+		if err != nil || i != 3 {
+			t.Fatal(dialect, " invalid InsertMany wrote rows : ", i, err)
+		}
+
+		mary, err := DB.Table("user").Upsert(JSONDoc{"email": "mary@test.com", "age": 31}, "email")
+		if err != nil || mary["name"] != "mary" || cast.ToInt(mary["age"]) != 31 || fmt.Sprint(mary[pk]) != fmt.Sprint(docs[1][pk]) {
+			t.Fatal(dialect, " Upsert update Error : ", mary, err)
+		}
+		anna, err := DB.Table("user").Upsert(JSONDoc{"name": "anna", "email": "anna@test.com"}, "email")
+		if err != nil || anna[pk] == nil {
+			t.Fatal(dialect, " Upsert insert Error : ", anna, err)
+		}
+		john, err := DB.Table("user").Upsert(JSONDoc{pk: docs[0][pk], "name": "johnny"})
+		if err != nil || john["name"] != "johnny" || john["email"] != "john@test.com" {
+			t.Fatal(dialect, " Upsert by primary key Error : ", john, err)
+		}
+		i, err = DB.Table("user").Count()
+		if err != nil || i != 4 {
+			t.Fatal(dialect, " Upsert Count Error : ", i, err)
+		}
+		_, err = DB.Table("user").Upsert(JSONDoc{"name": "mary", "age": 40}, "age")
+		if err == nil {
+			t.Fatal(dialect, " expected error upserting on a field that is not unique")
+		}
+		DB.Close()
+	}
+}
+
+func TestSession_UpsertVersioned(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	modelFile := filepath.Join(dir, "model.json")
+	model := strings.Replace(sqliteTestModel, `"name": "user",`, `"name": "user", "versioned": true,`, 1)
+	err = ioutil.WriteFile(modelFile, []byte(model), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dialect := range []string{"memory", "localdb", "sqlite"} {
+		config := ConfigDB{}
+		config.ModelFile = modelFile
+		config.Type = dialect
+		config.SyncDB = SyncCreate
+		config.Server = filepath.Join(dir, dialect+".db")
+		DB, err := NewOrm(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = DB.Table("user").Upsert(JSONDoc{"name": "mary", "email": "mary@test.com", "age": 30}, "email")
+		if err != nil {
+			t.Fatal(dialect, " Upsert insert Error : ", err)
+		}
+		doc := JSONDoc{"email": "mary@test.com", "age": 31, "_version": 7}
+		mary, err := DB.Table("user").Upsert(doc, "email")
+		if err != nil || fmt.Sprint(mary["_version"]) != "2" || fmt.Sprint(mary["age"]) != "31" {
+			t.Fatal(dialect, " Upsert update Error : ", mary, err)
+		}
+		if len(doc) != 3 || doc["_version"] != 7 {
+			t.Fatal(dialect, " Upsert changed the given document : ", doc)
+		}
+		DB.Close()
+	}
+}
@@ -0,0 +1,199 @@
+package gorgo
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// Cond - dialect neutral condition accepted by Session.Where, ex:
+//
+//	Where(And(Gte("age", 18), Or(Eq("city", "Rio"), IsNull("city"))))
+//
+// Each dialect compiles it into its native query, a mongo filter for mongo,
+// localdb and memory, a WHERE clause for the sql dialects. Fields may be
+// dotted paths in the document dialects and in sql schemaless tables.
+type Cond struct {
+	op    string
+	field string
+	value interface{}
+	conds []Cond
+}
+
+// Eq - field equals value
+func Eq(field string, value interface{}) Cond {
+	return Cond{op: "$eq", field: field, value: value}
+}
+
+// Ne - field differs from value
+func Ne(field string, value interface{}) Cond {
+	return Cond{op: "$ne", field: field, value: value}
+}
+
+// Gt - field greater than value
+func Gt(field string, value interface{}) Cond {
+	return Cond{op: "$gt", field: field, value: value}
+}
+
+// Gte - field greater than or equal to value
+func Gte(field string, value interface{}) Cond {
+	return Cond{op: "$gte", field: field, value: value}
+}
+
+// Lt - field less than value
+func Lt(field string, value interface{}) Cond {
+	return Cond{op: "$lt", field: field, value: value}
+}
+
+// Lte - field less than or equal to value
+func Lte(field string, value interface{}) Cond {
+	return Cond{op: "$lte", field: field, value: value}
+}
+
+// In - field equals one of the values, a single slice is expanded
+func In(field string, values ...interface{}) Cond {
+	if len(values) == 1 {
+		rv := reflect.ValueOf(values[0])
+		if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8 {
+			values = make([]interface{}, rv.Len())
+			for i := range values {
+				values[i] = rv.Index(i).Interface()
+			}
+		}
+	}
+	return Cond{op: "$in", field: field, value: values}
+}
+
+// Like - field matches the sql pattern, % is any text and _ any character.
+// Case sensitivity follows the database.
+func Like(field string, pattern string) Cond {
+	return Cond{op: "$like", field: field, value: pattern}
+}
+
+// IsNull - field is null or missing
+func IsNull(field string) Cond {
+	return Cond{op: "$null", field: field}
+}
+
+// And - every condition holds
+func And(conds ...Cond) Cond {
+	return Cond{op: "$and", conds: conds}
+}
+
+// Or - at least one condition holds
+func Or(conds ...Cond) Cond {
+	return Cond{op: "$or", conds: conds}
+}
+
+// Not - the condition does not hold
+func Not(cond Cond) Cond {
+	return Cond{op: "$not", conds: []Cond{cond}}
+}
+
+// empty - true for the zero Cond, no condition at all
+func (c Cond) empty() bool {
+	return c.op == ""
+}
+
+// filter - mongo style filter of the condition, as matchFilter and mgo read it
+func (c Cond) filter() map[string]interface{} {
+	switch c.op {
+	case "":
+		return map[string]interface{}{}
+	case "$and", "$or":
+		list := make([]interface{}, len(c.conds))
+		for i, cond := range c.conds {
+			list[i] = cond.filter()
+		}
+		return map[string]interface{}{c.op: list}
+	case "$not":
+		return map[string]interface{}{"$nor": []interface{}{c.conds[0].filter()}}
+	case "$in":
+		return map[string]interface{}{c.field: map[string]interface{}{"$in": c.value}}
+	case "$like":
+		return map[string]interface{}{c.field: map[string]interface{}{"$regex": likeToRegex(c.value.(string))}}
+	case "$null":
+		return map[string]interface{}{c.field: nil}
+	}
+	return map[string]interface{}{c.field: map[string]interface{}{c.op: c.value}}
+}
+
+// Filter - mongo style filter of the condition, empty for no condition, the
+// form a dialect outside the package translates
+func (c Cond) Filter() map[string]interface{} {
+	return c.filter()
+}
+
+// withCond - mongo style filter matching both the filter and the condition
+func withCond(filter map[string]interface{}, c Cond) map[string]interface{} {
+	if c.empty() {
+		return filter
+	}
+	if len(filter) == 0 {
+		return c.filter()
+	}
+	return map[string]interface{}{"$and": []interface{}{filter, c.filter()}}
+}
+
+// sqlizer - WHERE expression of the condition, column maps a field compared
+// with a value to its sql expression
+func (c Cond) sqlizer(column func(field string, value interface{}) string) sq.Sqlizer {
+	switch c.op {
+	case "", "$and", "$or":
+		var list []sq.Sqlizer
+		for _, cond := range c.conds {
+			list = append(list, cond.sqlizer(column))
+		}
+		if c.op == "$or" {
+			if len(list) == 0 {
+				return sq.Expr("1=0")
+			}
+			return sq.Or(list)
+		}
+		if len(list) == 0 {
+			return sq.Expr("1=1")
+		}
+		return sq.And(list)
+	case "$not":
+		return sq.Expr("NOT (?)", c.conds[0].sqlizer(column))
+	case "$eq":
+		return sq.Eq{column(c.field, c.value): c.value}
+	case "$ne":
+		return sq.NotEq{column(c.field, c.value): c.value}
+	case "$gt":
+		return sq.Gt{column(c.field, c.value): c.value}
+	case "$gte":
+		return sq.GtOrEq{column(c.field, c.value): c.value}
+	case "$lt":
+		return sq.Lt{column(c.field, c.value): c.value}
+	case "$lte":
+		return sq.LtOrEq{column(c.field, c.value): c.value}
+	case "$in":
+		return sq.Eq{column(c.field, c.value): c.value}
+	case "$like":
+		return sq.Like{column(c.field, c.value): c.value}
+	case "$null":
+		return sq.Eq{column(c.field, c.value): nil}
+	}
+	return sq.Expr("1=0")
+}
+
+// likeToRegex - anchored regular expression of a sql LIKE pattern
+func likeToRegex(pattern string) string {
+	var buf strings.Builder
+	buf.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			buf.WriteString(".*")
+		case '_':
+			buf.WriteString(".")
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	buf.WriteString("$")
+	return buf.String()
+}
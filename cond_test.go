@@ -0,0 +1,105 @@
+package gorgo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCond_SQL(t *testing.T) {
+	cond := And(Gte("age", 18), Or(In("city", []string{"Rio", "Recife"}), IsNull("city")), Not(Like("name", "jo%")))
+	stmt, args, err := cond.sqlizer(func(field string, _ interface{}) string {
+		return quoteANSI(field)
+	}).ToSql()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `("age" >= ? AND ("city" IN (?,?) OR "city" IS NULL) AND NOT ("name" LIKE ?))`
+	if stmt != expected {
+		t.Fatalf("expected %s, got %s", expected, stmt)
+	}
+	if !reflect.DeepEqual(args, []interface{}{18, "Rio", "Recife", "jo%"}) {
+		t.Fatalf("unexpected args %v", args)
+	}
+}
+
+func TestCond_Filter(t *testing.T) {
+	doc := map[string]interface{}{"name": "john Doe", "age": 25.0, "address": map[string]interface{}{"city": "Rio"}}
+	cases := []struct {
+		cond  Cond
+		match bool
+	}{
+		{Eq("name", "john Doe"), true},
+		{Ne("name", "john Doe"), false},
+		{And(Gt("age", 20), Lte("age", 25)), true},
+		{Or(Lt("age", 20), Eq("address.city", "Rio")), true},
+		{In("age", 10, 25), true},
+		{Like("name", "jo_n%"), true},
+		{Like("name", "Doe"), false},
+		{IsNull("email"), true},
+		{Not(IsNull("name")), true},
+	}
+	for _, c := range cases {
+		ok, err := matchFilter(doc, c.cond.filter())
+		if err != nil || ok != c.match {
+			t.Fatalf("%v: expected %v, got %v %v", c.cond.filter(), c.match, ok, err)
+		}
+	}
+}
+
+// TestCond_Portable - the same conditions give the same records on every dialect
+func TestCond_Portable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	modelFile := filepath.Join(dir, "model.json")
+	err = ioutil.WriteFile(modelFile, []byte(sqliteTestModel), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dialect := range []string{"memory", "localdb", "sqlite"} {
+		config := ConfigDB{}
+		config.ModelFile = modelFile
+		config.Type = dialect
+		config.SyncDB = SyncCreate
+		config.Server = filepath.Join(dir, dialect+".db")
+		DB, err := NewOrm(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i, name := range []string{"john Doe", "jane Doe", "mary Ann"} {
+			_, err := DB.Table("user").Insert(JSONDoc{"name": name, "email": name[:4] + "@test.com", "age": 20 + i*10})
+			if err != nil {
+				t.Fatal(dialect, " DB Create Error : ", err)
+			}
+		}
+
+		list, err := DB.Table("user").Where(And(Like("name", "%Doe"), Gt("age", 25))).Get()
+		if err != nil || len(list) != 1 || list[0]["name"] != "jane Doe" {
+			t.Fatal(dialect, " Where Error : ", list, err)
+		}
+		list, err = DB.Table("user").Where(Or(Eq("name", "john Doe"), In("age", 40, 50))).OrderBy("age desc").Get()
+		if err != nil || len(list) != 2 || list[0]["name"] != "mary Ann" {
+			t.Fatal(dialect, " Where Error : ", list, err)
+		}
+		i, err := DB.Table("user").Where(Not(Eq("name", "john Doe"))).Count()
+		if err != nil || i != 2 {
+			t.Fatal(dialect, " Count Error : ", i, err)
+		}
+		err = DB.Table("user").Where(Lt("age", 35)).DeleteByWhere()
+		if err != nil {
+			t.Fatal(dialect, " DeleteByWhere Error : ", err)
+		}
+		i, err = DB.Table("user").Count()
+		if err != nil || i != 1 {
+			t.Fatal(dialect, " Count Error : ", i, err)
+		}
+		DB.Close()
+	}
+}
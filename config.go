@@ -13,6 +13,7 @@ type ConfigDB struct {
 	UseSSL bool
 	ShowSQL bool
 	ModelFile string
+	// SyncDB - SyncCreate, SyncFull or SyncDryRun to reconcile the database with the model on NewOrm
 	SyncDB string
 	WatchInterval int
 	Validations FuncMap
@@ -0,0 +1,200 @@
+package gorgo
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// afterDialect - dialect walking the records in primary key order from a
+// key, localdb with the buntdb keys
+type afterDialect interface {
+	getAfter(tableName string, id string, limit int, q Query) ([]JSONDoc, error)
+}
+
+// After - read the records following the cursor returned by Scroll, the
+// first ones for an empty cursor
+func (s *Session) After(cursor string) *Session {
+	s.after = cursor
+	return s
+}
+
+// Scroll - at most Limit records after the cursor given to After, in the
+// OrderBy order completed by the primary key, and the cursor of the next
+// records, empty after the last one. Each page is a range query on the order
+// fields, it keeps its cost and skips nothing while records are inserted or
+// deleted, ex:
+//
+//	items, next, err := db.Table("user").OrderBy("-age").After(cursor).Limit(50).Scroll()
+//
+// The cursor is only valid with the same OrderBy, the order fields should not
+// be null and Offset is ignored.
+func (s *Session) Scroll() ([]JSONDoc, string, error) {
+	if err := s.check(); err != nil {
+		return nil, "", err
+	}
+	pk := s.orm.primaryKey(s.tableName)
+	terms := parseOrder(s.order)
+	hasPk := false
+	for _, t := range terms {
+		hasPk = hasPk || t.field == pk
+	}
+	if !hasPk {
+		terms = append(terms, orderTerm{field: pk})
+	}
+	var values []interface{}
+	if s.after != "" {
+		var err error
+		values, err = decodeCursor(s.after, terms)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	q := s.query()
+	q.Order = termsOrder(terms)
+	q.Limit = s.limit
+	if len(q.Columns) > 0 {
+		for _, t := range terms {
+			if !contains(q.Columns, t.field) {
+				q.Columns = append(q.Columns, t.field)
+			}
+		}
+	}
+
+	var list []JSONDoc
+	var err error
+	if ad, ok := s.orm.dialectDB.(afterDialect); ok && len(terms) == 1 && !terms[0].desc {
+		id := ""
+		if values != nil {
+			id = fmt.Sprint(values[0])
+		}
+		list, err = ad.getAfter(s.tableName, id, s.limit, q)
+	} else {
+		if values != nil {
+			after := keysetCond(terms, values)
+			if q.Cond.empty() {
+				q.Cond = after
+			} else {
+				q.Cond = And(q.Cond, after)
+			}
+		}
+		list, err = s.orm.dialectDB.GetManyByQuery(s.tableName, q)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if s.limit <= 0 || len(list) < s.limit {
+		return list, "", nil
+	}
+	next, err := encodeCursor(terms, list[len(list)-1])
+	return list, next, err
+}
+
+// keysetCond - records after the values in the order of the terms, ex:
+// a > 1 OR (a = 1 AND b > 2)
+func keysetCond(terms []orderTerm, values []interface{}) Cond {
+	var ors []Cond
+	for i, t := range terms {
+		var ands []Cond
+		for j := 0; j < i; j++ {
+			ands = append(ands, Eq(terms[j].field, values[j]))
+		}
+		if t.desc {
+			ands = append(ands, Lt(t.field, values[i]))
+		} else {
+			ands = append(ands, Gt(t.field, values[i]))
+		}
+		if len(ands) == 1 {
+			ors = append(ors, ands[0])
+		} else {
+			ors = append(ors, And(ands...))
+		}
+	}
+	if len(ors) == 1 {
+		return ors[0]
+	}
+	return Or(ors...)
+}
+
+// termsOrder - order string of the terms
+func termsOrder(terms []orderTerm) string {
+	var parts []string
+	for _, t := range terms {
+		if t.desc {
+			parts = append(parts, "-"+t.field)
+		} else {
+			parts = append(parts, t.field)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// cursor - content of the opaque cursors, the order fields and their values
+// in the last record read. Mongo ids and times are tagged to keep their type.
+type cursor struct {
+	Fields []string      `json:"f"`
+	Values []interface{} `json:"v"`
+}
+
+func encodeCursor(terms []orderTerm, last JSONDoc) (string, error) {
+	c := cursor{}
+	for _, t := range terms {
+		v, _ := lookupField(last, t.field)
+		switch value := v.(type) {
+		case bson.ObjectId:
+			v = map[string]interface{}{"$oid": value.Hex()}
+		case time.Time:
+			v = map[string]interface{}{"$date": value.Format(time.RFC3339Nano)}
+		}
+		c.Fields = append(c.Fields, t.field)
+		c.Values = append(c.Values, v)
+	}
+	encoded, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(encoded), nil
+}
+
+func decodeCursor(s string, terms []orderTerm) ([]interface{}, error) {
+	invalid := fmt.Errorf("invalid cursor for the order %s", termsOrder(terms))
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, invalid
+	}
+	var c cursor
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if decoder.Decode(&c) != nil || len(c.Fields) != len(terms) || len(c.Values) != len(terms) {
+		return nil, invalid
+	}
+	for i, t := range terms {
+		if c.Fields[i] != t.field {
+			return nil, invalid
+		}
+		switch value := c.Values[i].(type) {
+		case json.Number:
+			if n, err := value.Int64(); err == nil {
+				c.Values[i] = n
+			} else if f, err := value.Float64(); err == nil {
+				c.Values[i] = f
+			}
+		case map[string]interface{}:
+			if oid, ok := value["$oid"].(string); ok && bson.IsObjectIdHex(oid) {
+				c.Values[i] = bson.ObjectIdHex(oid)
+			} else if date, ok := value["$date"].(string); ok {
+				c.Values[i], err = time.Parse(time.RFC3339Nano, date)
+				if err != nil {
+					return nil, invalid
+				}
+			}
+		}
+	}
+	return c.Values, nil
+}
@@ -1,6 +1,9 @@
 package gorgo
 
-import "encoding/json"
+import (
+	"context"
+	"encoding/json"
+)
 
 //DialectSQL : interface for sql database dialect
 type Dialect interface {
@@ -9,18 +12,79 @@ type Dialect interface {
 	Count(string) (int, error)
 	Create(string, JSONDoc) (JSONDoc, error)
 	CreateInterface(string, interface{}) error
-	GetById(string, string) (JSONDoc, error)
-	GetOneByQuery(string, string) (JSONDoc, error)
-	GetManyByQuery(string, string, ...interface{}) ([]JSONDoc, error)
-	GetAll(string, int, int, string) ([]JSONDoc, error)
-	GetAllBySearch(string, string, string, int, int, string) ([]JSONDoc, error)
+	GetById(string, string, ...string) (JSONDoc, error)
+	GetOneByQuery(string, Query) (JSONDoc, error)
+	GetManyByQuery(string, Query) ([]JSONDoc, error)
+	// GetAll - records of the table, skipping offset records and returning
+	// at most limit, 0 for all of them
+	GetAll(string, int, int, Query) ([]JSONDoc, error)
+	GetAllBySearch(string, string, string, int, int, Query) ([]JSONDoc, error)
 	Update(string, JSONDoc) error
 	Delete(string, string) error
-	DeleteByWhere(string, string) error
-	CountByWhere(string, string) (int, error)
+	DeleteByWhere(string, Query) error
+	CountByWhere(string, Query) (int, error)
+	// GetByGroup - first group of a mongo style $group stage. Deprecated:
+	// use Session.GroupBy, portable and returning every group
 	GetByGroup(string, map[string]interface{}) (JSONDoc, error)
 }
 
+//TxDialect - dialect bound to an open transaction, every call runs inside it
+//until Commit or Rollback
+type TxDialect interface {
+	Dialect
+	Commit() error
+	Rollback() error
+}
+
+//TxBeginner - dialect able to group several operations in a transaction
+type TxBeginner interface {
+	Begin() (TxDialect, error)
+}
+
+//ContextDialect - dialect able to bind its calls to a context, cancelling
+//them or bounding them by its deadline
+type ContextDialect interface {
+	WithContext(context.Context) Dialect
+}
+
+//SchemaSyncer - dialect able to reconcile the database with the tables of the
+//model, mode is one of the ConfigDB.SyncDB modes
+type SchemaSyncer interface {
+	SyncSchema(mode string) ([]SchemaChange, error)
+}
+
+//modelDialect - dialect loaded with the model file, the ORM reads the table
+//options from it
+type modelDialect interface {
+	dbModel() *model
+}
+
+//keyedDialect - dialect whose records are not keyed by _id
+type keyedDialect interface {
+	pk(tableName string) string
+}
+
+//strictDialect - dialect whose tables only get the fields of the model from
+//the ORM, like the timestamps
+type strictDialect interface {
+	strictFields(tableName string) bool
+}
+
+//Query - options of a read, Where is a native query of the dialect and Cond
+//a portable one, both apply when set. Order is "field desc, other asc" (or
+//"-field") and Columns restricts the returned fields, the id is always
+//returned. GetManyByQuery skips Offset records and returns at most Limit, 0
+//for all of them.
+type Query struct {
+	Where   string
+	Params  []interface{}
+	Cond    Cond
+	Order   string
+	Columns []string
+	Offset  int
+	Limit   int
+}
+
 //JSONDoc map string for interfaces like json
 type JSONDoc map[string]interface{}
 
@@ -0,0 +1,124 @@
+package gorgo
+
+import "sync"
+
+// Hook - lifecycle function of a table. It receives a session over the table,
+// bound to the transaction of the operation, and the document, which it may
+// change. An error aborts the operation and rolls back its transaction.
+type Hook func(s *Session, doc JSONDoc) error
+
+const (
+	beforeInsert = "beforeInsert"
+	afterInsert  = "afterInsert"
+	beforeUpdate = "beforeUpdate"
+	afterUpdate  = "afterUpdate"
+	beforeDelete = "beforeDelete"
+	afterDelete  = "afterDelete"
+)
+
+// hookSet - hooks by event and table, shared by the ORM copies of WithContext
+// and Transaction
+type hookSet struct {
+	mutex sync.RWMutex
+	funcs map[string][]Hook
+}
+
+func (h *hookSet) add(event string, table string, fn Hook) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.funcs == nil {
+		h.funcs = make(map[string][]Hook)
+	}
+	h.funcs[event+":"+table] = append(h.funcs[event+":"+table], fn)
+}
+
+// has - true when a hook is registered for one of the events of the table
+func (h *hookSet) has(table string, events ...string) bool {
+	if h == nil {
+		return false
+	}
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	for _, event := range events {
+		if len(h.funcs[event+":"+table]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// run - call the hooks of the event in registration order, stopping at the first error
+func (h *hookSet) run(s *Session, event string, doc JSONDoc) error {
+	if h == nil {
+		return nil
+	}
+	h.mutex.RLock()
+	funcs := h.funcs[event+":"+s.tableName]
+	h.mutex.RUnlock()
+	for _, fn := range funcs {
+		// the hook writes as the same actor and reads the same records
+		hs := s.orm.Table(s.tableName)
+		hs.actor, hs.withDeleted = s.actor, s.withDeleted
+		err := fn(hs, doc)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *ORM) on(event string, table string, fn Hook) {
+	if d.hooks == nil {
+		d.hooks = &hookSet{}
+	}
+	d.hooks.add(event, table, fn)
+}
+
+// OnBeforeInsert - call fn with the document before it is inserted in table
+func (d *ORM) OnBeforeInsert(table string, fn Hook) {
+	d.on(beforeInsert, table, fn)
+}
+
+// OnAfterInsert - call fn with the inserted document, its id already set
+func (d *ORM) OnAfterInsert(table string, fn Hook) {
+	d.on(afterInsert, table, fn)
+}
+
+// OnBeforeUpdate - call fn with the document before it is updated in table
+func (d *ORM) OnBeforeUpdate(table string, fn Hook) {
+	d.on(beforeUpdate, table, fn)
+}
+
+// OnAfterUpdate - call fn with the updated document
+func (d *ORM) OnAfterUpdate(table string, fn Hook) {
+	d.on(afterUpdate, table, fn)
+}
+
+// OnBeforeDelete - call fn with the stored document before it is deleted from table
+func (d *ORM) OnBeforeDelete(table string, fn Hook) {
+	d.on(beforeDelete, table, fn)
+}
+
+// OnAfterDelete - call fn with the deleted document
+func (d *ORM) OnAfterDelete(table string, fn Hook) {
+	d.on(afterDelete, table, fn)
+}
+
+// hooked - run fn, in a transaction when hooks of the events are registered
+// for the table and the dialect supports it, so a failing hook undoes the
+// operation
+func (s *Session) hooked(fn func(s *Session) error, events ...string) error {
+	if !s.orm.hooks.has(s.tableName, events...) {
+		return fn(s)
+	}
+	return s.atomic(fn)
+}
+
+// atomic - run fn with a session bound to a transaction when the dialect has them
+func (s *Session) atomic(fn func(s *Session) error) error {
+	return s.orm.atomic(func(txOrm *ORM) error {
+		tx := *s
+		tx.orm = txOrm
+		return fn(&tx)
+	})
+}
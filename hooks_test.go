@@ -0,0 +1,101 @@
+package gorgo
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cast"
+)
+
+func TestORM_Hooks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	modelFile := filepath.Join(dir, "model.json")
+	err = ioutil.WriteFile(modelFile, []byte(sqliteTestModel), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dialect := range []string{"memory", "localdb", "sqlite"} {
+		config := ConfigDB{}
+		config.ModelFile = modelFile
+		config.Type = dialect
+		config.SyncDB = SyncCreate
+		config.Server = filepath.Join(dir, dialect+".db")
+		DB, err := NewOrm(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var deleted []string
+		var hookActor interface{}
+		hookDeleted := false
+		DB.OnBeforeInsert("user", func(s *Session, doc JSONDoc) error {
+			if doc["name"] == "bad" {
+				return fmt.Errorf("bad name")
+			}
+			doc["email"] = strings.ToLower(cast.ToString(doc["email"]))
+			return nil
+		})
+		DB.OnAfterInsert("user", func(s *Session, doc JSONDoc) error {
+			if doc["name"] == "rollback" {
+				return fmt.Errorf("rejected after insert")
+			}
+			return nil
+		})
+		DB.OnBeforeUpdate("user", func(s *Session, doc JSONDoc) error {
+			doc["age"] = 99
+			return nil
+		})
+		DB.OnAfterUpdate("user", func(s *Session, doc JSONDoc) error {
+			hookActor, hookDeleted = s.actor, s.withDeleted
+			return nil
+		})
+		DB.OnAfterDelete("user", func(s *Session, doc JSONDoc) error {
+			deleted = append(deleted, cast.ToString(doc["name"]))
+			return nil
+		})
+
+		user, err := DB.Table("user").Insert(JSONDoc{"name": "john Doe", "email": "JOHN@test.com"})
+		if err != nil || user["email"] != "john@test.com" {
+			t.Fatal(dialect, " DB Create Error : ", user, err)
+		}
+		_, err = DB.Table("user").Insert(JSONDoc{"name": "bad", "email": "bad@test.com"})
+		if err == nil {
+			t.Fatal(dialect, " expected before insert hook error")
+		}
+		_, err = DB.Table("user").Insert(JSONDoc{"name": "rollback", "email": "rb@test.com"})
+		if err == nil {
+			t.Fatal(dialect, " expected after insert hook error")
+		}
+		i, err := DB.Table("user").Count()
+		if err != nil || i != 1 {
+			t.Fatal(dialect, " the failed inserts were not rolled back: ", i, err)
+		}
+
+		err = DB.Table("user").As("admin").WithDeleted().Update(user)
+		if err != nil {
+			t.Fatal(dialect, " DB Update Error : ", err)
+		}
+		if hookActor != "admin" || !hookDeleted {
+			t.Fatal(dialect, " hook session without the actor and options : ", hookActor, hookDeleted)
+		}
+		stored, err := DB.Table("user").GetByID(cast.ToString(user["_id"]))
+		if err != nil || cast.ToInt(stored["age"]) != 99 {
+			t.Fatal(dialect, " before update hook not applied: ", stored, err)
+		}
+
+		err = DB.Table("user").DeleteByID(cast.ToString(user["_id"]))
+		if err != nil || len(deleted) != 1 || deleted[0] != "john Doe" {
+			t.Fatal(dialect, " DB Delete Error : ", deleted, err)
+		}
+		DB.Close()
+	}
+}
@@ -1,20 +1,30 @@
 package gorgo
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 	"github.com/spf13/cast"
+	"github.com/tidwall/buntdb"
 )
 
 
 func TestLocalDialect_Create(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
 	config := ConfigDB{}
 	config.ModelFile = "model.json"
 	config.Type = "localdb"
-	config.Server = "localtest.db"
-	config.WatchModel = true
+	config.Server = filepath.Join(dir, "localtest.db")
 
-	DB, err := NewDB(config)
+	DB, err := NewOrm(config)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -40,7 +50,7 @@ func TestLocalDialect_Create(t *testing.T) {
 	t.Logf("User created id[ %v ] ", userRet["_id"])
 
 
-	list, err := DB.Table("user").Limit(6).Offset(1).Get()
+	list, err := DB.Table("user").Limit(6).Offset(0).Get()
 	if err != nil {
 		t.Fatal("DB GetAll Error : ", err)
 	}
@@ -61,4 +71,395 @@ func TestLocalDialect_Create(t *testing.T) {
 	}
 	t.Logf("User deleted !!")
 
+	// the unique email is released by the delete
+	delete(user, "_id")
+	_, err = DB.Table("user").Insert(user)
+	if err != nil {
+		t.Fatal("DB Create after Delete Error : ", err)
+	}
+	err = DB.Table("user").Where(Eq("email", "jd@test.com")).DeleteByWhere()
+	if err != nil {
+		t.Fatal("DB DeleteByWhere Error : ", err)
+	}
+	delete(user, "_id")
+	_, err = DB.Table("user").Insert(user)
+	if err != nil {
+		t.Fatal("DB Create after DeleteByWhere Error : ", err)
+	}
+}
+
+func TestLocalDialect_InsertStruct(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := ConfigDB{}
+	config.ModelFile = "model.json"
+	config.Type = "localdb"
+	config.Server = filepath.Join(dir, "localtest.db")
+
+	DB, err := NewOrm(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer DB.Close()
+
+	type user struct {
+		ID    string `json:"_id"`
+		Name  string `json:"name"`
+		Email string `json:"email"`
+		Cpf   string `json:"cpf"`
+		Cnpj  string `json:"cnpj"`
+		Age   int    `json:"age"`
+		Teste string `json:"teste"`
+	}
+	u := &user{Name: "john Doe", Email: "jd@test.com", Cpf: "23749817030", Cnpj: "78470985000106", Age: 25, Teste: "asdf"}
+
+	err = DB.Table("user").InsertStruct(u)
+	if err != nil {
+		t.Fatal("DB InsertStruct Error : ", err)
+	}
+	if u.ID == "" {
+		t.Fatal("generated _id was not written back")
+	}
+	stored, err := DB.Table("user").GetByID(u.ID)
+	if err != nil || stored["name"] != "john Doe" || stored["_created"] == nil {
+		t.Fatal("DB GetByID Error : ", stored, err)
+	}
+
+	err = DB.Table("user").InsertStruct(&user{Name: "other", Email: "jd@test.com", Cpf: "23749817030", Cnpj: "78470985000106", Age: 30, Teste: "a"})
+	if err == nil {
+		t.Fatal("expected unique key violation")
+	}
+	err = DB.Table("user").InsertStruct(&user{Name: "x", Email: "x@test.com", Cpf: "23749817030", Cnpj: "78470985000106", Teste: "a"})
+	if err == nil {
+		t.Fatal("expected minlen validation error")
+	}
+}
+
+func TestLocalDialect_Query(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	modelFile := filepath.Join(dir, "model.json")
+	err = ioutil.WriteFile(modelFile, []byte(sqliteTestModel), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := ConfigDB{}
+	config.ModelFile = modelFile
+	config.Type = "localdb"
+	config.Server = filepath.Join(dir, "localtest.db")
+
+	DB, err := NewOrm(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer DB.Close()
+
+	users := []JSONDoc{
+		{"name": "john Doe", "email": "mary@test.com", "age": 25, "address": map[string]interface{}{"city": "Rio"}},
+		{"name": "mary Doe", "email": "md@test.com", "age": 40, "address": map[string]interface{}{"city": "Recife"}},
+	}
+	for _, u := range users {
+		_, err := DB.Table("user").Insert(u)
+		if err != nil {
+			t.Fatal("DB Create Error : ", err)
+		}
+	}
+
+	// the name is matched on its field only, not inside the email
+	list, err := DB.Table("user").Where(`{"name": {"$regex": ?}}`, "^mary").Get()
+	if err != nil || len(list) != 1 || list[0]["name"] != "mary Doe" {
+		t.Fatal("DB GetManyByQuery Error : ", list, err)
+	}
+	list, err = DB.Table("user").Where(`{"age": {"$gte": ?, "$lt": ?}, "address.city": ?}`, 20, 30, "Rio").Get()
+	if err != nil || len(list) != 1 || list[0]["name"] != "john Doe" {
+		t.Fatal("DB GetManyByQuery Error : ", list, err)
+	}
+	i, err := DB.dialectDB.CountByWhere("user", Query{Where: `{"age": {"$gt": ?}}`, Params: []interface{}{30}})
+	if err != nil || i != 1 {
+		t.Fatal("DB CountByWhere Error : ", i, err)
+	}
+	_, err = DB.Table("user").Where(`{"age": ?}`).Get()
+	if err == nil {
+		t.Fatal("expected missing param error")
+	}
+}
+
+const localIndexModel = `{
+  "schema" : "local-index",
+  "tables" :[
+    {
+      "name": "user",
+      "fields": [
+        "_id,object,autoincrement",
+        "name, string, index",
+        "email, string, unique",
+        "age, int, index"
+      ]
+    }
+  ]
+}`
+
+func TestLocalDialect_Index(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	modelFile := filepath.Join(dir, "model.json")
+	err = ioutil.WriteFile(modelFile, []byte(localIndexModel), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := ConfigDB{}
+	config.ModelFile = modelFile
+	config.Type = "localdb"
+	config.Server = filepath.Join(dir, "localtest.db")
+
+	DB, err := NewOrm(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer DB.Close()
+	dialect := DB.dialectDB.(*LocalDialect)
+
+	var ids []string
+	for i, name := range []string{"john", "jane", "mary", "bob"} {
+		doc, err := DB.Table("user").Insert(JSONDoc{"name": name, "email": name + "@test.com", "age": 40 - i*5})
+		if err != nil {
+			t.Fatal("DB Create Error : ", err)
+		}
+		ids = append(ids, cast.ToString(doc["_id"]))
+	}
+
+	plan := dialect.planScan("user", map[string]interface{}{"age": map[string]interface{}{"$gte": 30}, "name": "jane"}, "")
+	if plan == nil || plan.index != "idxuser:name" || plan.equal == "" {
+		t.Fatalf("expected equality plan on name, got %+v", plan)
+	}
+	if plan := dialect.planScan("user", map[string]interface{}{"email": "x"}, "-age"); plan == nil || !plan.ordered || !plan.desc {
+		t.Fatalf("expected ordered plan on age, got %+v", plan)
+	}
+
+	list, err := DB.Table("user").Where(`{"age": {"$gt": ?, "$lte": ?}}`, 25, 35).OrderBy("age").Get()
+	if err != nil || len(list) != 2 || list[0]["name"] != "mary" || list[1]["name"] != "jane" {
+		t.Fatal("DB range query Error : ", list, err)
+	}
+	list, err = DB.dialectDB.GetAll("user", 0, 2, Query{Order: "-age"})
+	if err != nil || len(list) != 2 || list[0]["name"] != "john" || list[1]["name"] != "jane" {
+		t.Fatal("DB sorted GetAll Error : ", list, err)
+	}
+
+	// indexes follow updates and deletes
+	user, err := DB.Table("user").GetByID(ids[0])
+	if err != nil {
+		t.Fatal("DB GetByID Error : ", err)
+	}
+	user["name"] = "johnny"
+	err = DB.Table("user").Update(user)
+	if err != nil {
+		t.Fatal("DB Update Error : ", err)
+	}
+	err = DB.Table("user").DeleteByID(ids[1])
+	if err != nil {
+		t.Fatal("DB Delete Error : ", err)
+	}
+	for name, expected := range map[string]int{"john": 0, "johnny": 1, "jane": 0} {
+		i, err := DB.Table("user").Where(Eq("name", name)).Count()
+		if err != nil || i != expected {
+			t.Fatal("DB indexed Count Error : ", name, i, err)
+		}
+	}
+}
+
+func TestLocalDialect_SyncDB(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	modelFile := filepath.Join(dir, "model.json")
+	model := strings.Replace(localIndexModel, `"email, string, unique"`, `"email, string"`, 1)
+	err = ioutil.WriteFile(modelFile, []byte(model), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := ConfigDB{}
+	config.ModelFile = modelFile
+	config.Type = "localdb"
+	config.Server = filepath.Join(dir, "localtest.db")
+	DB, err := NewOrm(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"john", "jane"} {
+		_, err := DB.Table("user").Insert(JSONDoc{"name": name, "email": name + "@test.com", "age": 30})
+		if err != nil {
+			t.Fatal("DB Create Error : ", err)
+		}
+	}
+	DB.Close()
+
+	err = ioutil.WriteFile(modelFile, []byte(localIndexModel), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.SyncDB = SyncCreate
+	DB, err = NewOrm(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer DB.Close()
+	changes, err := DB.SyncSchema(SyncDryRun)
+	if err != nil || len(changes) != 0 {
+		t.Fatal("expected the unique keys to be synced : ", changes, err)
+	}
+	_, err = DB.Table("user").Insert(JSONDoc{"name": "other", "email": "jane@test.com", "age": 20})
+	if err == nil {
+		t.Fatal("expected unique key violated on a document stored before the sync")
+	}
+}
+
+func TestLocalDialect_DeleteByWhereNumericID(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := ConfigDB{}
+	config.ModelFile = "model.json"
+	config.Type = "localdb"
+	config.Server = filepath.Join(dir, "localtest.db")
+	DB, err := NewOrm(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer DB.Close()
+
+	// a record written by another program, with a numeric id
+	err = DB.dialectDB.(*LocalDialect).DB.Update(func(tx *buntdb.Tx) error {
+		_, _, err := tx.Set("user:5", `{"_id": 5, "name": "legacy", "age": 70}`, nil)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = DB.Table("user").Where(Eq("name", "legacy")).DeleteByWhere()
+	if err != nil {
+		t.Fatal("DB DeleteByWhere Error : ", err)
+	}
+	list, err := DB.Table("user").Where(Eq("name", "legacy")).Get()
+	if err != nil || len(list) != 0 {
+		t.Fatal("expected the record to be deleted : ", list, err)
+	}
+}
+
+func TestLocalDialect_UniqueAlias(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	modelFile := filepath.Join(dir, "model.json")
+	model := strings.Replace(localIndexModel, `"email, string, unique"`, `"email, string, unique, alias=mail"`, 1)
+	err = ioutil.WriteFile(modelFile, []byte(model), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// memory and localdb key the unique values by the stored column
+	for _, dbType := range []string{"memory", "localdb"} {
+		config := ConfigDB{}
+		config.ModelFile = modelFile
+		config.Type = dbType
+		config.Server = filepath.Join(dir, "unique.db")
+		DB, err := NewOrm(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		john, err := DB.Table("user").Insert(JSONDoc{"name": "john", "email": "jd@test.com", "age": 30})
+		if err != nil {
+			t.Fatal(dbType, " DB Create Error : ", err)
+		}
+		if john["mail"] != "jd@test.com" {
+			t.Fatal(dbType, " expected the email stored as mail : ", john)
+		}
+		_, err = DB.Table("user").Insert(JSONDoc{"name": "jane", "email": "jd@test.com", "age": 20})
+		if err == nil {
+			t.Fatal(dbType, " expected unique key violated")
+		}
+		_, err = DB.Table("user").Insert(JSONDoc{"name": "jane", "email": "jane@test.com", "age": 20})
+		if err != nil {
+			t.Fatal(dbType, " DB Create Error : ", err)
+		}
+		DB.Close()
+	}
+}
+
+func TestLocalDialect_UniqueColumns(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	modelFile := filepath.Join(dir, "model.json")
+	model := strings.Replace(localIndexModel, `"age, int, index"`, `"age, int, index", "login, string, unique"`, 1)
+	err = ioutil.WriteFile(modelFile, []byte(model), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := ConfigDB{}
+	config.ModelFile = modelFile
+	config.Type = "localdb"
+	config.Server = filepath.Join(dir, "localtest.db")
+	DB, err := NewOrm(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer DB.Close()
+
+	// two unique fields holding the same value do not collide
+	_, err = DB.Table("user").Insert(JSONDoc{"name": "john", "email": "jd@test.com", "login": "jd@test.com"})
+	if err != nil {
+		t.Fatal("DB Create Error : ", err)
+	}
+	_, err = DB.Table("user").Insert(JSONDoc{"name": "jane", "email": "jane@test.com", "login": "jd@test.com"})
+	if err == nil {
+		t.Fatal("expected unique key violated on login")
+	}
+
+	// a document and its unique key stored without the column by an older version
+	err = DB.dialectDB.(*LocalDialect).DB.Update(func(tx *buntdb.Tx) error {
+		_, _, err := tx.Set("user:legacy", `{"_id": "legacy", "name": "legacy", "email": "old@test.com", "login": "old"}`, nil)
+		if err != nil {
+			return err
+		}
+		_, _, err = tx.Set("unique_user:old@test.com", "legacy", nil)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes, err := DB.SyncSchema(SyncCreate)
+	if err != nil || len(changes) != 3 || changes[0].Action != "drop index" || !changes[0].Applied {
+		t.Fatal("expected the unique keys to be rebuilt : ", changes, err)
+	}
+	changes, err = DB.SyncSchema(SyncDryRun)
+	if err != nil || len(changes) != 0 {
+		t.Fatal("expected the unique keys to be synced : ", changes, err)
+	}
+	_, err = DB.Table("user").Insert(JSONDoc{"name": "other", "email": "old@test.com", "login": "other"})
+	if err == nil {
+		t.Fatal("expected unique key violated on a rebuilt key")
+	}
 }
@@ -1,13 +1,12 @@
 package gorgo
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"regexp"
 	"strings"
-	"time"
 
-	"github.com/rgobbo/fsmodify"
 	"github.com/spf13/cast"
 	"github.com/tidwall/buntdb"
 	"gopkg.in/mgo.v2/bson"
@@ -17,28 +16,25 @@ type LocalDialect struct {
 	DB     *buntdb.DB
 	Model  *model
 	Config ConfigDB
+
+	// tx - open transaction of a dialect returned by Begin
+	tx *buntdb.Tx
+	// ctx - context of a dialect returned by WithContext
+	ctx context.Context
 }
 
-func (s *LocalDialect) InitDB(config ConfigDB) error {
-	if config.ModelFile != "" {
-		s.Model = new(model)
-		err := s.Model.LoadFile(config.ModelFile)
-		if err != nil {
-			return err
-		}
-		if config.WatchInterval > 0 {
-			go fsmodify.NewWatcher(config.ModelFile, "", config.WatchInterval, func(filename string) {
-				s.Model = new(model)
-				err := s.Model.LoadFile(config.ModelFile)
-				if err != nil {
-					log.Println(err)
-				}
-			})
-		}
+func init() {
+	RegisterDialect("localdb", func() Dialect { return &LocalDialect{} })
+}
 
-	} else {
-		s.Model = new(model)
+func (s *LocalDialect) InitDB(config ConfigDB) error {
+	mod, err := loadModel(config, func(reloaded *model) {
+		s.Model = reloaded
+	})
+	if err != nil {
+		return err
 	}
+	s.Model = mod
 
 	server := config.Server
 	db, err := buntdb.Open(server)
@@ -55,7 +51,7 @@ func (s *LocalDialect) InitDB(config ConfigDB) error {
 
 func (s *LocalDialect) generateIndexes() error {
 
-	err := s.DB.Update(func(tx *buntdb.Tx) error {
+	err := s.update(func(tx *buntdb.Tx) error {
 
 		indexes, err := tx.Indexes()
 		if err != nil {
@@ -83,6 +79,13 @@ func (s *LocalDialect) generateIndexes() error {
 			}
 		}
 
+		for _, name := range s.Model.tableNames() {
+			err = s.createFieldIndexes(tx, name, indexes)
+			if err != nil {
+				return err
+			}
+		}
+
 		return err
 	})
 
@@ -98,16 +101,95 @@ func contains(slice []string, elem string) bool {
 	return false
 }
 
+func (s *LocalDialect) dbModel() *model {
+	return s.Model
+}
+
 //CloseDB  - close database
 func (s *LocalDialect) CloseDB() error {
+	if s.tx != nil {
+		return fmt.Errorf("Could not close the database inside a transaction")
+	}
 	return s.DB.Close()
 }
 
+//WithContext  - copy of the dialect failing its calls once ctx is done,
+//scans stop in the middle of the collection
+func (s *LocalDialect) WithContext(ctx context.Context) Dialect {
+	return &LocalDialect{DB: s.DB, Model: s.Model, Config: s.Config, tx: s.tx, ctx: ctx}
+}
+
+// ctxErr - error of the context, nil when there is none
+func (s *LocalDialect) ctxErr() error {
+	if s.ctx == nil {
+		return nil
+	}
+	return s.ctx.Err()
+}
+
+// view - run fn in a read only transaction, or in the open one
+func (s *LocalDialect) view(fn func(tx *buntdb.Tx) error) error {
+	if err := s.ctxErr(); err != nil {
+		return err
+	}
+	if s.tx != nil {
+		return fn(s.tx)
+	}
+	return s.DB.View(fn)
+}
+
+// update - run fn in a write transaction, or in the open one
+func (s *LocalDialect) update(fn func(tx *buntdb.Tx) error) error {
+	if err := s.ctxErr(); err != nil {
+		return err
+	}
+	if s.tx != nil {
+		return fn(s.tx)
+	}
+	return s.DB.Update(fn)
+}
+
+//Begin  - open a write transaction, the returned dialect runs every call
+//inside it. buntdb has a single writer, other writes wait for its end.
+func (s *LocalDialect) Begin() (TxDialect, error) {
+	if s.tx != nil {
+		return nil, fmt.Errorf("Transaction already started")
+	}
+	if err := s.ctxErr(); err != nil {
+		return nil, err
+	}
+	tx, err := s.DB.Begin(true)
+	if err != nil {
+		return nil, err
+	}
+	return &LocalDialect{DB: s.DB, Model: s.Model, Config: s.Config, tx: tx, ctx: s.ctx}, nil
+}
+
+//Commit  - commit the transaction opened by Begin
+func (s *LocalDialect) Commit() error {
+	if s.tx == nil {
+		return fmt.Errorf("No transaction to commit")
+	}
+	return s.tx.Commit()
+}
+
+//Rollback  - discard the transaction opened by Begin
+func (s *LocalDialect) Rollback() error {
+	if s.tx == nil {
+		return fmt.Errorf("No transaction to rollback")
+	}
+	return s.tx.Rollback()
+}
+
 func (s *LocalDialect) Count(collection string) (int, error) {
 	var ret int
-	err := s.DB.View(func(tx *buntdb.Tx) error {
+	err := s.view(func(tx *buntdb.Tx) error {
 		count := 0
+		var e error
 		err := tx.Ascend("idx"+collection, func(key, value string) bool {
+			if e = s.ctxErr(); e != nil {
+				return false
+			}
 			if strings.HasPrefix(key, collection+":") {
 				count++
 			}
@@ -115,28 +197,24 @@ func (s *LocalDialect) Count(collection string) (int, error) {
 
 		})
 		ret = count
-		return err
+		if err != nil {
+			return err
+		}
+		return e
 	})
 	return ret, err
 
 }
 
-func (s *LocalDialect) CountByWhere(collection string, query string) (int, error) {
-	var ret int
-	err := s.DB.View(func(tx *buntdb.Tx) error {
-		count := 0
-		err := tx.Ascend("idx"+collection, func(key, value string) bool {
-			res := strings.Contains(value, query)
-			if res {
-				count++
-			}
-			return true
-		})
-		ret = count
-		return err
+func (s *LocalDialect) CountByWhere(collection string, query Query) (int, error) {
+	filter, err := s.filter(query)
+	if err != nil {
+		return 0, err
+	}
+	list, err := s.scan(collection, s.planScan(collection, filter, ""), 0, func(doc JSONDoc) (bool, error) {
+		return matchFilter(doc, filter)
 	})
-	return ret, err
-
+	return len(list), err
 }
 
 func (s *LocalDialect) Create(collection string, data JSONDoc) (JSONDoc, error) {
@@ -147,8 +225,6 @@ func (s *LocalDialect) Create(collection string, data JSONDoc) (JSONDoc, error)
 	uniques := []string{}
 	var newDoc JSONDoc
 
-	data["_created"] = time.Now()
-
 	err := validateFields(collection, data, s.Model, s.Config.Validations)
 	if err != nil {
 		return newDoc, err
@@ -157,15 +233,15 @@ func (s *LocalDialect) Create(collection string, data JSONDoc) (JSONDoc, error)
 	if val, ok := s.Model.Tables[collection]; ok {
 		for _, f := range val.Fields {
 			if f.Unique == true {
-				if data[f.Name] == nil {
+				if data[f.column()] == nil {
 					return newDoc, fmt.Errorf("Unique field %s, could not be null", f.Name)
 				}
-				uniques = append(uniques, "unique_"+collection+":"+cast.ToString(data[f.Name]))
+				uniques = append(uniques, uniqueKey(collection, f, data[f.column()]))
 			}
 		}
 	}
 
-	err = s.DB.Update(func(tx *buntdb.Tx) error {
+	err = s.update(func(tx *buntdb.Tx) error {
 
 		_, _, err := tx.Set("BUCKETS:"+collection, collection, nil)
 		if err != nil {
@@ -189,6 +265,10 @@ func (s *LocalDialect) Create(collection string, data JSONDoc) (JSONDoc, error)
 				return err
 			}
 		}
+		err = s.createFieldIndexes(tx, collection, indexes)
+		if err != nil {
+			return err
+		}
 
 		for _, s := range uniques {
 			v, _ := tx.Get(s, true)
@@ -213,13 +293,22 @@ func (s *LocalDialect) Create(collection string, data JSONDoc) (JSONDoc, error)
 	return newDoc, err
 }
 
+// CreateInterface - insert a struct through Create, writing the generated _id back into it
 func (s *LocalDialect) CreateInterface(collection string, i interface{}) error {
-	return nil
+	data, err := encodeStruct(i)
+	if err != nil {
+		return err
+	}
+	newDoc, err := s.Create(collection, data)
+	if err != nil {
+		return err
+	}
+	return setStructField(i, "_id", newDoc["_id"])
 }
 
-func (s *LocalDialect) GetById(collection string, id string) (JSONDoc, error) {
+func (s *LocalDialect) GetById(collection string, id string, columns ...string) (JSONDoc, error) {
 	var data JSONDoc
-	err := s.DB.View(func(tx *buntdb.Tx) error {
+	err := s.view(func(tx *buntdb.Tx) error {
 		key := collection + ":" + id
 		// Retrieve the record
 		item, err := tx.Get(key)
@@ -235,7 +324,7 @@ func (s *LocalDialect) GetById(collection string, id string) (JSONDoc, error) {
 		return nil
 	})
 
-	return data, err
+	return projectDoc(data, columns, "_id"), err
 }
 
 func (s *LocalDialect) Update(collection string, data JSONDoc) error {
@@ -252,7 +341,7 @@ func (s *LocalDialect) Update(collection string, data JSONDoc) error {
 		return err
 	}
 
-	err = s.DB.Update(func(tx *buntdb.Tx) error {
+	err = s.update(func(tx *buntdb.Tx) error {
 		item, err := tx.Get(key)
 		if err != nil {
 			return err
@@ -265,20 +354,23 @@ func (s *LocalDialect) Update(collection string, data JSONDoc) error {
 		if e != nil {
 			return e
 		}
+		if s.Model.versioned(collection) && !sameVersion(olddata[versionField], data) {
+			return ErrStaleObject
+		}
 
 		if val, ok := s.Model.Tables[collection]; ok {
 			for _, f := range val.Fields {
 				if f.Unique == true {
-					if data[f.Name] == nil {
+					if data[f.column()] == nil {
 						return fmt.Errorf("Unique field %s, could not be null", f.Name)
 					}
-					s := "unique_" + collection + ":" + cast.ToString(data[f.Name])
+					s := uniqueKey(collection, f, data[f.column()])
 					v, _ := tx.Get(s, true)
 
 					if v != "" && v != cast.ToString(data["_id"]) {
 						return fmt.Errorf("Unique key violated - %s ", v)
 					} else {
-						oldunique := "unique_" + collection + ":" + cast.ToString(olddata[f.Name])
+						oldunique := uniqueKey(collection, f, olddata[f.column()])
 						_, _ = tx.Delete(oldunique)
 
 						_, _, err = tx.Set(s, sid, nil)
@@ -290,6 +382,15 @@ func (s *LocalDialect) Update(collection string, data JSONDoc) error {
 			}
 		}
 
+		if s.Model.versioned(collection) {
+			version := cast.ToInt64(data[versionField])
+			data[versionField] = version + 1
+			_, _, err = tx.Set(key, data.ToString(), nil)
+			if err != nil {
+				data[versionField] = version
+			}
+			return err
+		}
 		_, _, err = tx.Set(key, data.ToString(), nil)
 		if err != nil {
 			return err
@@ -301,87 +402,332 @@ func (s *LocalDialect) Update(collection string, data JSONDoc) error {
 	return err
 }
 
-func (s *LocalDialect) Delete(collection string, id string) error {
-	err := s.DB.Update(func(tx *buntdb.Tx) error {
-
-		key := collection + ":" + id
-
-		_, err := tx.Delete(key)
-		if err != nil {
-			return err
+//CreateMany  - insert the documents in one buntdb transaction, none when one fails
+func (s *LocalDialect) CreateMany(collection string, docs []JSONDoc) error {
+	err := validateRows(collection, docs, s.Model, s.Config.Validations)
+	if err != nil {
+		return err
+	}
+	return s.update(func(tx *buntdb.Tx) error {
+		txd := &LocalDialect{DB: s.DB, Model: s.Model, Config: s.Config, tx: tx, ctx: s.ctx}
+		for i, doc := range docs {
+			_, err := txd.Create(collection, doc)
+			if err != nil {
+				return BulkError{{Index: i, Err: err}}
+			}
 		}
+		return nil
+	})
+}
 
-		err = tx.Ascend("idx_unique"+collection, func(key, value string) bool {
-			if value == id {
-				_, err := tx.Delete(key)
-				if err != nil {
-					return false
-				}
+//Upsert  - insert or update the document matching the keys in one buntdb transaction
+func (s *LocalDialect) Upsert(collection string, doc JSONDoc, keys []string, onInsert JSONDoc) (JSONDoc, error) {
+	var stored JSONDoc
+	err := s.update(func(tx *buntdb.Tx) error {
+		txd := &LocalDialect{DB: s.DB, Model: s.Model, Config: s.Config, tx: tx, ctx: s.ctx}
+		var err error
+		stored, err = upsertDoc(txd, collection, doc, keys, onInsert, func(doc JSONDoc, insert bool) (JSONDoc, error) {
+			if insert {
+				return txd.Create(collection, doc)
 			}
-			return true
+			return doc, txd.Update(collection, doc)
 		})
+		return err
+	})
+	return stored, err
+}
 
+//Patch  - change some fields of the document, read and written back in the
+//same buntdb transaction
+func (s *LocalDialect) Patch(collection string, id string, ops []FieldOp) error {
+	err := validateChanged(collection, changedFields(ops), s.Model, s.Config.Validations)
+	if err != nil {
 		return err
+	}
+	return s.update(func(tx *buntdb.Tx) error {
+		txd := &LocalDialect{DB: s.DB, Model: s.Model, Config: s.Config, tx: tx, ctx: s.ctx}
+		doc, err := txd.GetById(collection, id)
+		if err != nil {
+			return err
+		}
+		err = applyOps(doc, ops)
+		if err != nil {
+			return err
+		}
+		return txd.Update(collection, doc)
 	})
+}
 
-	return err
+func (s *LocalDialect) Delete(collection string, id string) error {
+	return s.update(func(tx *buntdb.Tx) error {
+		return deleteRecord(tx, collection, id)
+	})
 }
 
-func (s *LocalDialect) DeleteByWhere(collection string, query string) error {
+func (s *LocalDialect) DeleteByWhere(collection string, query Query) error {
 	list, err := s.GetManyByQuery(collection, query)
 	if err != nil {
 		return err
 	}
-	err = s.DB.Update(func(tx *buntdb.Tx) error {
-
+	return s.update(func(tx *buntdb.Tx) error {
 		for _, obj := range list {
-			key := collection + ":" + obj["_id"].(string)
-
-			_, err := tx.Delete(key)
+			err := deleteRecord(tx, collection, cast.ToString(obj["_id"]))
 			if err != nil {
 				return err
 			}
+		}
+		return nil
+	})
+}
 
-			err = tx.Ascend("idx_unique"+collection, func(key, value string) bool {
-				if value == obj["_id"].(string) {
-					_, err := tx.Delete(key)
-					if err != nil {
-						return false
-					}
-				}
-				return true
-			})
+// deleteRecord - delete the record and its unique keys. buntdb refuses the
+// writes during an iteration, the keys are collected first.
+func deleteRecord(tx *buntdb.Tx, collection string, id string) error {
+	_, err := tx.Delete(collection + ":" + id)
+	if err != nil {
+		return err
+	}
+	var keys []string
+	err = tx.Ascend("idx_unique"+collection, func(key, value string) bool {
+		if value == id {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		_, err = tx.Delete(key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
+// scan - documents of the collection accepted by match, walking the planned
+// index or the whole collection. An ordered plan stops after max documents.
+func (s *LocalDialect) scan(collection string, plan *indexScan, max int, match func(doc JSONDoc) (bool, error)) ([]JSONDoc, error) {
+	var data []JSONDoc
+	err := s.view(func(tx *buntdb.Tx) error {
+		indexes, err := tx.Indexes()
+		if err != nil {
 			return err
+		}
+		if !contains(indexes, "idx"+collection) {
+			// nothing was ever stored in the collection
+			return nil
+		}
+		if plan != nil && !contains(indexes, plan.index) {
+			plan = nil
+		}
 
+		var e error
+		iterator := func(key, value string) bool {
+			e = s.ctxErr()
+			if e != nil {
+				return false
+			}
+			var single JSONDoc
+			e = json.Unmarshal([]byte(value), &single)
+			if e != nil {
+				return false
+			}
+			var ok bool
+			ok, e = match(single)
+			if e != nil {
+				return false
+			}
+			if ok {
+				data = append(data, single)
+			}
+			return max <= 0 || plan == nil || !plan.ordered || len(data) < max
 		}
 
-		return nil
+		if plan != nil {
+			err = plan.walk(tx, iterator)
+		} else {
+			err = tx.Ascend("idx"+collection, iterator)
+		}
+		if err != nil {
+			return err
+		}
+		return e
 	})
+	return data, err
+}
 
-	return err
+// filter - mongo style filter of the json query, ex: {"age": {"$gt": ?}, "address.city": ?},
+// with the same syntax and operators as the mongo dialect, and of the condition
+func (s *LocalDialect) filter(q Query) (map[string]interface{}, error) {
+	filter, err := parseFilter(q.Where, q.Params...)
+	if err != nil {
+		return nil, err
+	}
+	return withCond(filter, q.Cond), nil
+}
+
+// find - sorted and projected documents of the collection matching the query,
+// max bounds the documents read when an index gives the order
+func (s *LocalDialect) find(collection string, q Query, max int) ([]JSONDoc, error) {
+	filter, err := s.filter(q)
+	if err != nil {
+		return nil, err
+	}
+	plan := s.planScan(collection, filter, q.Order)
+	list, err := s.scan(collection, plan, max, func(doc JSONDoc) (bool, error) {
+		return matchFilter(doc, filter)
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortDocs(list, q.Order)
+	for i, doc := range list {
+		list[i] = projectDoc(doc, q.Columns, "_id")
+	}
+	return list, nil
+}
+
+// getAfter - documents matching the query with a key after the collection
+// key of id, walking the buntdb keys, at most limit of them
+func (s *LocalDialect) getAfter(collection string, id string, limit int, q Query) ([]JSONDoc, error) {
+	filter, err := s.filter(q)
+	if err != nil {
+		return nil, err
+	}
+	var data []JSONDoc
+	err = s.view(func(tx *buntdb.Tx) error {
+		prefix := collection + ":"
+		var e error
+		err := tx.AscendGreaterOrEqual("", prefix+id, func(key, value string) bool {
+			if !strings.HasPrefix(key, prefix) {
+				return false
+			}
+			if id != "" && key == prefix+id {
+				return true
+			}
+			if e = s.ctxErr(); e != nil {
+				return false
+			}
+			var single JSONDoc
+			e = json.Unmarshal([]byte(value), &single)
+			if e != nil {
+				return false
+			}
+			var ok bool
+			ok, e = matchFilter(single, filter)
+			if e != nil {
+				return false
+			}
+			if ok {
+				data = append(data, projectDoc(single, q.Columns, "_id"))
+			}
+			return limit <= 0 || len(data) < limit
+		})
+		if err != nil {
+			return err
+		}
+		return e
+	})
+	return data, err
+}
+
+// streamBatch - documents read by each buntdb transaction of Stream
+const streamBatch = 100
+
+//Stream  - documents of the query read in batches of keys, each in its own
+//read transaction so writes can run between them. An ordered query is read
+//at once to be sorted.
+func (s *LocalDialect) Stream(collection string, q Query) (*Rows, error) {
+	if q.Order != "" {
+		list, err := s.GetManyByQuery(collection, q)
+		if err != nil {
+			return nil, err
+		}
+		return listRows(list), nil
+	}
+	var batch []JSONDoc
+	lastID := ""
+	skipped, read := 0, 0
+	last := false
+	next := func() (JSONDoc, error) {
+		for {
+			if q.Limit > 0 && read >= q.Limit {
+				return nil, nil
+			}
+			if len(batch) == 0 {
+				if last {
+					return nil, nil
+				}
+				var err error
+				batch, err = s.getAfter(collection, lastID, streamBatch, q)
+				if err != nil {
+					return nil, err
+				}
+				last = len(batch) < streamBatch
+				if len(batch) == 0 {
+					return nil, nil
+				}
+				lastID = cast.ToString(batch[len(batch)-1]["_id"])
+			}
+			doc := batch[0]
+			batch = batch[1:]
+			if skipped < q.Offset {
+				skipped++
+				continue
+			}
+			read++
+			return doc, nil
+		}
+	}
+	return newRows(next, nil), nil
 }
 
-func (s *LocalDialect) GetAll(tableName string, skip int, limit int, sorted string) ([]JSONDoc, error) {
+// readMax - documents to read for a page of limit documents after offset, 0 for all
+func readMax(offset int, limit int) int {
+	if limit <= 0 {
+		return 0
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return offset + limit
+}
+
+func (s *LocalDialect) GetAll(tableName string, offset int, limit int, q Query) ([]JSONDoc, error) {
 	var result []JSONDoc
 	count := 0
-	skipLimit := skip * limit
-	initLimit := skipLimit - limit
-	err := s.DB.View(func(tx *buntdb.Tx) error {
+	if offset < 0 {
+		offset = 0
+	}
+	max := readMax(offset, limit)
+	if q.Order != "" {
+		list, err := s.find(tableName, Query{Order: q.Order, Columns: q.Columns}, max)
+		if err != nil {
+			return nil, err
+		}
+		return pageDocs(list, offset, limit), nil
+	}
+	err := s.view(func(tx *buntdb.Tx) error {
 
+		var e error
 		err := tx.Ascend("idx"+tableName, func(key, value string) bool {
-			if count >= initLimit && count < skipLimit {
+			if e = s.ctxErr(); e != nil {
+				return false
+			}
+			if count >= offset && (max == 0 || count < max) {
 
 				var single JSONDoc
 				err := json.Unmarshal([]byte(value), &single)
 				if err != nil {
 					return false
 				}
-				result = append(result, single)
+				result = append(result, projectDoc(single, q.Columns, "_id"))
 				count++
 				return true
 
-			} else if count < initLimit {
+			} else if count < offset {
 				count++
 				return true
 			} else {
@@ -389,63 +735,64 @@ func (s *LocalDialect) GetAll(tableName string, skip int, limit int, sorted stri
 			}
 
 		})
-		return err
+		if err != nil {
+			return err
+		}
+		return e
 
 	})
 	return result, err
 }
-func (s *LocalDialect) GetOneByQuery(collection string, query string) (JSONDoc, error) {
-	var data JSONDoc
-	err := s.DB.View(func(tx *buntdb.Tx) error {
-
-		err := tx.Ascend("idx"+collection, func(key, value string) bool {
-			res := strings.Contains(value, query)
-			if res {
-				err := json.Unmarshal([]byte(value), &data)
-				if err != nil {
-					log.Println("Error when unmarshal data")
-				}
-				return false
-			}
-			return true
-
-		})
-		return err
-
-	})
-
-	return data, err
+func (s *LocalDialect) GetOneByQuery(collection string, q Query) (JSONDoc, error) {
+	list, err := s.find(collection, q, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("Item not found")
+	}
+	return list[0], nil
 }
-func (s *LocalDialect) GetManyByQuery(collection string, query string, params ...interface{}) ([]JSONDoc, error) {
-	var data []JSONDoc
-	err := s.DB.View(func(tx *buntdb.Tx) error {
-
-		err := tx.Ascend("idx"+collection, func(key, value string) bool {
-			res := strings.Contains(value, query)
-			if res {
-				err := json.Unmarshal([]byte(value), &data)
-				if err != nil {
-					log.Println("Error when unmarshal data")
-				}
-
-			}
-			return true
-
-		})
-		return err
-
-	})
-
-	return data, err
-	return data, nil
+func (s *LocalDialect) GetManyByQuery(collection string, q Query) ([]JSONDoc, error) {
+	list, err := s.find(collection, q, readMax(q.Offset, q.Limit))
+	if err != nil {
+		return nil, err
+	}
+	return pageDocs(list, q.Offset, q.Limit), nil
 }
-func (s *LocalDialect) GetAllBySearch(collection string, text string, field string, page int, qtd int, sorted string) ([]JSONDoc, error) {
-	var data []JSONDoc
-	return data, nil
-
+func (s *LocalDialect) GetAllBySearch(collection string, text string, field string, page int, qtd int, q Query) ([]JSONDoc, error) {
+	re, err := regexp.Compile(text)
+	if err != nil {
+		return nil, err
+	}
+	list, err := s.scan(collection, nil, 0, func(doc JSONDoc) (bool, error) {
+		v, ok := lookupField(doc, field)
+		return ok && re.MatchString(cast.ToString(v)), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortDocs(list, q.Order)
+	for i, doc := range list {
+		list[i] = projectDoc(doc, q.Columns, "_id")
+	}
+	if page < 1 {
+		page = 1
+	}
+	return pageDocs(list, (page-1)*qtd, qtd), nil
 }
+// GetByGroup - evaluate a mongo style $group stage, returning the first group
 func (s *LocalDialect) GetByGroup(collection string, query map[string]interface{}) (JSONDoc, error) {
-	var data JSONDoc
-	return data, nil
-
+	list, err := s.find(collection, Query{}, 0)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := groupDocs(list, query)
+	if err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 {
+		return JSONDoc{}, nil
+	}
+	return groups[0], nil
 }
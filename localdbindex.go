@@ -0,0 +1,311 @@
+package gorgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/spf13/cast"
+	"github.com/tidwall/buntdb"
+)
+
+// Secondary indexes of the localdb dialect. Fields declared with the index
+// option in the model, ex: "email, string, index", get a buntdb json index
+// over the documents of the collection. buntdb keeps them up to date on every
+// Set and Delete, reads walk an index instead of the whole collection when
+// the query compares an indexed field or is sorted by one.
+
+// fieldIndex - name of the buntdb index of a collection field
+func fieldIndex(collection string, column string) string {
+	return "idx" + collection + ":" + column
+}
+
+// uniqueKey - key holding the id of the document with the value of a unique
+// field, the column keeps two unique fields sharing a value apart
+func uniqueKey(collection string, f *field, value interface{}) string {
+	return "unique_" + collection + ":" + f.column() + ":" + cast.ToString(value)
+}
+
+// indexedColumns - columns of the collection declared with index in the model
+func (s *LocalDialect) indexedColumns(collection string) []string {
+	var columns []string
+	if val, ok := s.Model.Tables[collection]; ok {
+		for _, f := range val.Fields {
+			if f.Index {
+				columns = append(columns, f.column())
+			}
+		}
+	}
+	return columns
+}
+
+// createFieldIndexes - create the missing json indexes of the collection
+func (s *LocalDialect) createFieldIndexes(tx *buntdb.Tx, collection string, indexes []string) error {
+	for _, column := range s.indexedColumns(collection) {
+		name := fieldIndex(collection, column)
+		if contains(indexes, name) {
+			continue
+		}
+		err := tx.CreateIndex(name, collection+":*", buntdb.IndexJSONCaseSensitive(column))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexScan - walk over a field index, restricted to the documents equal to
+// equal or between from and to (json pivots like {"age": 18})
+type indexScan struct {
+	index       string
+	column      string
+	desc        bool
+	ordered     bool
+	equal       string
+	from        string
+	to          string
+	toInclusive bool
+}
+
+// planScan - index walk for the filter and the order, nil for a full scan.
+// Equality on an indexed field is preferred to a range, then to the order.
+func (s *LocalDialect) planScan(collection string, filter map[string]interface{}, order string) *indexScan {
+	columns := s.indexedColumns(collection)
+	if len(columns) == 0 {
+		return nil
+	}
+
+	conds := map[string][]interface{}{}
+	collect := func(m map[string]interface{}) {
+		for key, cond := range m {
+			if contains(columns, key) {
+				conds[key] = append(conds[key], cond)
+			}
+		}
+	}
+	collect(filter)
+	if list, ok := filter["$and"].([]interface{}); ok {
+		for _, item := range list {
+			if m, ok := toFilter(item); ok {
+				collect(m)
+			}
+		}
+	}
+	keys := make([]string, 0, len(conds))
+	for key := range conds {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var ranged *indexScan
+	for _, key := range keys {
+		plan := &indexScan{index: fieldIndex(collection, key), column: key}
+		for _, cond := range conds[key] {
+			ops, ok := toFilter(cond)
+			if !ok || !isOperatorDoc(ops) {
+				ops = map[string]interface{}{"$eq": cond}
+			}
+			for op, arg := range ops {
+				pivot, err := json.Marshal(map[string]interface{}{key: arg})
+				if err != nil {
+					continue
+				}
+				switch op {
+				case "$eq":
+					plan.equal = string(pivot)
+				case "$gt", "$gte":
+					plan.from = string(pivot)
+				case "$lt":
+					plan.to, plan.toInclusive = string(pivot), false
+				case "$lte":
+					plan.to, plan.toInclusive = string(pivot), true
+				}
+			}
+		}
+		if plan.equal != "" {
+			return plan
+		}
+		if ranged == nil && (plan.from != "" || plan.to != "") {
+			ranged = plan
+		}
+	}
+	if ranged != nil {
+		return ranged
+	}
+
+	terms := parseOrder(order)
+	if len(terms) == 1 && contains(columns, terms[0].field) {
+		return &indexScan{
+			index:   fieldIndex(collection, terms[0].field),
+			column:  terms[0].field,
+			desc:    terms[0].desc,
+			ordered: true,
+		}
+	}
+	return nil
+}
+
+// walk - call iterator over the planned range of the index
+func (p *indexScan) walk(tx *buntdb.Tx, iterator func(key, value string) bool) error {
+	switch {
+	case p.equal != "":
+		return tx.AscendEqual(p.index, p.equal, iterator)
+	case p.desc:
+		return tx.Descend(p.index, iterator)
+	}
+
+	if p.to != "" {
+		less := buntdb.IndexJSONCaseSensitive(p.column)
+		bounded := iterator
+		iterator = func(key, value string) bool {
+			if p.toInclusive && less(p.to, value) || !p.toInclusive && !less(value, p.to) {
+				return false
+			}
+			return bounded(key, value)
+		}
+	}
+	if p.from != "" {
+		return tx.AscendGreaterOrEqual(p.index, p.from, iterator)
+	}
+	return tx.Ascend(p.index, iterator)
+}
+
+// SyncSchema - store the unique keys of the documents written before their
+// field was declared unique in the model, a value shared by two documents is
+// reported and its field left as it is. The unique keys no document holds
+// anymore, like the ones stored without their column by older versions, are
+// dropped first. The field indexes live in memory and are rebuilt on open,
+// they need no sync.
+func (s *LocalDialect) SyncSchema(mode string) ([]SchemaChange, error) {
+	var changes []SchemaChange
+	err := s.update(func(tx *buntdb.Tx) error {
+		indexes, err := tx.Indexes()
+		if err != nil {
+			return err
+		}
+		for _, name := range s.Model.tableNames() {
+			if !contains(indexes, "idx"+name) {
+				continue
+			}
+			if contains(indexes, "idx_unique"+name) {
+				change, stale, err := s.planStaleUniqueKeys(tx, name)
+				if err != nil {
+					return err
+				}
+				if len(stale) > 0 {
+					if change.appliesIn(mode) {
+						for _, key := range stale {
+							_, err = tx.Delete(key)
+							if err != nil {
+								return err
+							}
+						}
+						change.Applied = true
+					}
+					changes = append(changes, change)
+				}
+			}
+			for _, f := range s.Model.Tables[name].Fields {
+				if !f.Unique {
+					continue
+				}
+				change, keys, err := s.planUniqueKeys(tx, name, f)
+				if err != nil {
+					return err
+				}
+				if len(keys) == 0 && change.Detail == "" {
+					continue
+				}
+				if change.Detail == "" {
+					change.Detail = fmt.Sprintf("unique keys of %d documents", len(keys))
+					if change.appliesIn(mode) {
+						if !contains(indexes, "idx_unique"+name) {
+							err = tx.CreateIndex("idx_unique"+name, "unique_"+name+":*", buntdb.IndexString)
+							if err != nil {
+								return err
+							}
+							indexes = append(indexes, "idx_unique"+name)
+						}
+						for key, id := range keys {
+							_, _, err = tx.Set(key, id, nil)
+							if err != nil {
+								return err
+							}
+						}
+						change.Applied = true
+					}
+				}
+				changes = append(changes, change)
+			}
+		}
+		return nil
+	})
+	return changes, err
+}
+
+// planStaleUniqueKeys - unique keys of the collection that no document holds
+// for one of its unique fields. buntdb refuses the writes during an
+// iteration, the keys are collected first.
+func (s *LocalDialect) planStaleUniqueKeys(tx *buntdb.Tx, collection string) (SchemaChange, []string, error) {
+	var stale []string
+	var err error
+	tx.Ascend("idx_unique"+collection, func(key, value string) bool {
+		var stored string
+		stored, err = tx.Get(collection+":"+value, true)
+		if err == buntdb.ErrNotFound {
+			stale, err = append(stale, key), nil
+			return true
+		}
+		if err != nil {
+			return false
+		}
+		var doc JSONDoc
+		err = json.Unmarshal([]byte(stored), &doc)
+		if err != nil {
+			return false
+		}
+		for _, f := range s.Model.Tables[collection].Fields {
+			if f.Unique && doc[f.column()] != nil && uniqueKey(collection, f, doc[f.column()]) == key {
+				return true
+			}
+		}
+		stale = append(stale, key)
+		return true
+	})
+	change := SchemaChange{Table: collection, Action: "drop index", Detail: fmt.Sprintf("%d stale unique keys", len(stale))}
+	return change, stale, err
+}
+
+// planUniqueKeys - unique keys missing for the documents of the collection,
+// the change Detail is set when two documents share a value
+func (s *LocalDialect) planUniqueKeys(tx *buntdb.Tx, collection string, f *field) (SchemaChange, map[string]string, error) {
+	change := SchemaChange{Table: collection, Column: f.Name, Action: "create index"}
+	keys := map[string]string{}
+	var err error
+	tx.Ascend("idx"+collection, func(key, value string) bool {
+		var doc JSONDoc
+		err = json.Unmarshal([]byte(value), &doc)
+		if err != nil {
+			return false
+		}
+		if doc[f.column()] == nil {
+			return true
+		}
+		id := cast.ToString(doc["_id"])
+		unique := uniqueKey(collection, f, doc[f.column()])
+		stored, _ := tx.Get(unique, true)
+		if stored == "" {
+			stored = keys[unique]
+		}
+		switch stored {
+		case id:
+		case "":
+			keys[unique] = id
+		default:
+			change.Detail = fmt.Sprintf("value %v is shared by documents %s and %s", doc[f.column()], stored, id)
+			return false
+		}
+		return true
+	})
+	return change, keys, err
+}
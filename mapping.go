@@ -0,0 +1,328 @@
+package gorgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/spf13/cast"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	objectIDType = reflect.TypeOf(bson.ObjectId(""))
+)
+
+// structFieldName - document key of a struct field from its gorgo, json or
+// bson tag, the field name when untagged. ok is false for skipped fields.
+func structFieldName(sf reflect.StructField) (string, bool) {
+	if sf.PkgPath != "" && !sf.Anonymous {
+		return "", false
+	}
+	for _, key := range []string{"gorgo", "json", "bson"} {
+		tag, ok := sf.Tag.Lookup(key)
+		if !ok {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if name == "-" {
+			return "", false
+		}
+		if name != "" {
+			return name, true
+		}
+	}
+	return sf.Name, true
+}
+
+// encodeStruct - document with the fields of i, a pointer to a struct, keyed
+// by the same tags decodeDocs reads. Fields tagged omitempty are left out
+// when they hold their zero value.
+func encodeStruct(i interface{}) (JSONDoc, error) {
+	rv := reflect.ValueOf(i)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("need a pointer to a struct, received %T", i)
+	}
+	doc := JSONDoc{}
+	encodeFields(rv.Elem(), doc)
+	return doc, nil
+}
+
+func encodeFields(rv reflect.Value, doc JSONDoc) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		name, ok := structFieldName(sf)
+		if !ok {
+			continue
+		}
+		fv := rv.Field(i)
+
+		_, tagged := sf.Tag.Lookup("gorgo")
+		if sf.Anonymous && !tagged && fv.Kind() == reflect.Struct {
+			encodeFields(fv, doc)
+			continue
+		}
+		if hasTagOption(sf, "omitempty") && isZeroValue(fv) {
+			continue
+		}
+		doc[name] = encodeValue(fv)
+	}
+}
+
+func encodeValue(fv reflect.Value) interface{} {
+	switch fv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if fv.IsNil() {
+			return nil
+		}
+		return encodeValue(fv.Elem())
+	case reflect.Struct:
+		if fv.Type() != timeType {
+			doc := JSONDoc{}
+			encodeFields(fv, doc)
+			return map[string]interface{}(doc)
+		}
+	}
+	return fv.Interface()
+}
+
+func hasTagOption(sf reflect.StructField, option string) bool {
+	for _, key := range []string{"gorgo", "json", "bson"} {
+		if tag, ok := sf.Tag.Lookup(key); ok {
+			for _, opt := range strings.Split(tag, ",")[1:] {
+				if opt == option {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	return false
+}
+
+func isZeroValue(v reflect.Value) bool {
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
+
+// setStructField - assign value to the field of i stored under the document key
+func setStructField(i interface{}, key string, value interface{}) error {
+	rv := reflect.ValueOf(i)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("need a pointer to a struct, received %T", i)
+	}
+	return decodeStruct(map[string]interface{}{key: value}, rv.Elem())
+}
+
+// decodeDocs - fill dest, a pointer to a struct or to a slice of structs, with the documents
+func decodeDocs(list []JSONDoc, dest interface{}) error {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("dest must be a non nil pointer, received %T", dest)
+	}
+	rv = rv.Elem()
+
+	if rv.Kind() != reflect.Slice {
+		if len(list) == 0 {
+			return ErrNotFound
+		}
+		return assignValue(rv, list[0])
+	}
+
+	slice := reflect.MakeSlice(rv.Type(), len(list), len(list))
+	for i, doc := range list {
+		err := assignValue(slice.Index(i), doc)
+		if err != nil {
+			return err
+		}
+	}
+	rv.Set(slice)
+	return nil
+}
+
+// decodeStruct - copy the document keys into the matching struct fields
+func decodeStruct(doc map[string]interface{}, rv reflect.Value) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		name, ok := structFieldName(sf)
+		if !ok {
+			continue
+		}
+		fv := rv.Field(i)
+
+		_, tagged := sf.Tag.Lookup("gorgo")
+		if sf.Anonymous && !tagged && fv.Kind() == reflect.Struct {
+			err := decodeStruct(doc, fv)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, found := doc[name]
+		if !found {
+			for k, v := range doc {
+				if strings.EqualFold(k, name) {
+					value, found = v, true
+					break
+				}
+			}
+		}
+		if !found {
+			continue
+		}
+		err := assignValue(fv, value)
+		if err != nil {
+			return fmt.Errorf("Field %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// assignValue - set fv from a document value, converting between the types
+// the dialects return (float64 json numbers, time strings, ObjectIds...)
+func assignValue(fv reflect.Value, value interface{}) error {
+	if value == nil {
+		fv.Set(reflect.Zero(fv.Type()))
+		return nil
+	}
+	vv := reflect.ValueOf(value)
+	ft := fv.Type()
+
+	switch {
+	case ft == objectIDType:
+		switch id := value.(type) {
+		case bson.ObjectId:
+			fv.Set(reflect.ValueOf(id))
+		case string:
+			if !bson.IsObjectIdHex(id) {
+				return fmt.Errorf("invalid ObjectId %q", id)
+			}
+			fv.Set(reflect.ValueOf(bson.ObjectIdHex(id)))
+		default:
+			return fmt.Errorf("cannot convert %T to ObjectId", value)
+		}
+		return nil
+	case ft == timeType:
+		t, err := cast.ToTimeE(value)
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(t))
+		return nil
+	case ft.Kind() == reflect.Interface:
+		if vv.Type().Implements(ft) {
+			fv.Set(vv)
+			return nil
+		}
+	}
+
+	switch ft.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(ft.Elem())
+		err := assignValue(elem.Elem(), value)
+		if err != nil {
+			return err
+		}
+		fv.Set(elem)
+		return nil
+	case reflect.String:
+		if id, ok := value.(bson.ObjectId); ok {
+			fv.SetString(id.Hex())
+			return nil
+		}
+		str, err := cast.ToStringE(value)
+		if err != nil {
+			return err
+		}
+		fv.SetString(str)
+		return nil
+	case reflect.Bool:
+		b, err := cast.ToBoolE(value)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := cast.ToInt64E(value)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := cast.ToUint64E(value)
+		if err != nil {
+			return err
+		}
+		fv.SetUint(u)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f, err := cast.ToFloat64E(value)
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+		return nil
+	case reflect.Struct:
+		if doc, ok := toFilter(value); ok {
+			return decodeStruct(doc, fv)
+		}
+	case reflect.Slice:
+		if list, ok := value.([]interface{}); ok {
+			slice := reflect.MakeSlice(ft, len(list), len(list))
+			for i, item := range list {
+				err := assignValue(slice.Index(i), item)
+				if err != nil {
+					return err
+				}
+			}
+			fv.Set(slice)
+			return nil
+		}
+		if str, ok := value.(string); ok && ft.Elem().Kind() == reflect.Uint8 {
+			fv.SetBytes([]byte(str))
+			return nil
+		}
+	case reflect.Map:
+		if doc, ok := toFilter(value); ok && ft.Key().Kind() == reflect.String {
+			m := reflect.MakeMapWithSize(ft, len(doc))
+			for k, item := range doc {
+				elem := reflect.New(ft.Elem()).Elem()
+				err := assignValue(elem, item)
+				if err != nil {
+					return err
+				}
+				m.SetMapIndex(reflect.ValueOf(k).Convert(ft.Key()), elem)
+			}
+			fv.Set(m)
+			return nil
+		}
+	}
+
+	if vv.Type().AssignableTo(ft) {
+		fv.Set(vv)
+		return nil
+	}
+	if vv.Type().ConvertibleTo(ft) {
+		fv.Set(vv.Convert(ft))
+		return nil
+	}
+
+	// last resort, values stored as json text
+	raw := []byte(cast.ToString(value))
+	if len(raw) == 0 {
+		return fmt.Errorf("cannot convert %T to %s", value, ft)
+	}
+	target := reflect.New(ft)
+	if err := json.Unmarshal(raw, target.Interface()); err != nil {
+		return fmt.Errorf("cannot convert %T to %s", value, ft)
+	}
+	fv.Set(target.Elem())
+	return nil
+}
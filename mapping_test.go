@@ -0,0 +1,112 @@
+package gorgo
+
+import (
+	"testing"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+type mappingAddress struct {
+	City string `json:"city"`
+}
+
+type mappingBase struct {
+	ID bson.ObjectId `bson:"_id"`
+}
+
+type mappingUser struct {
+	mappingBase
+	Name    string            `gorgo:"name" json:"fullName"`
+	Email   string            `json:"email,omitempty"`
+	Age     int               `json:"age"`
+	Score   *float64          `json:"score"`
+	Created time.Time         `gorgo:"created"`
+	Tags    []string          `json:"tags"`
+	Address mappingAddress    `json:"address"`
+	Extra   map[string]string `json:"extra"`
+	Skipped string            `gorgo:"-"`
+}
+
+func TestDecodeDocs(t *testing.T) {
+	id := bson.NewObjectId()
+	created := time.Date(2018, 1, 2, 10, 11, 12, 0, time.UTC)
+	list := []JSONDoc{
+		{
+			"_id":     id.Hex(),
+			"name":    "john Doe",
+			"email":   "jd@test.com",
+			"age":     float64(25),
+			"score":   "9.5",
+			"created": created.Format(time.RFC3339),
+			"tags":    []interface{}{"a", "b"},
+			"address": map[string]interface{}{"city": "rio"},
+			"extra":   bson.M{"k": "v"},
+			"Skipped": "no",
+		},
+		{"_id": bson.NewObjectId(), "name": "jane Doe", "age": int64(31), "created": created},
+	}
+
+	var users []mappingUser
+	err := decodeDocs(list, &users)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 {
+		t.Fatalf("expected 2 users, got %d", len(users))
+	}
+	u := users[0]
+	if u.ID != id || u.Name != "john Doe" || u.Email != "jd@test.com" || u.Age != 25 ||
+		u.Score == nil || *u.Score != 9.5 || !u.Created.Equal(created) || len(u.Tags) != 2 ||
+		u.Address.City != "rio" || u.Extra["k"] != "v" || u.Skipped != "" {
+		t.Fatalf("unexpected decode %+v", u)
+	}
+	if users[1].Age != 31 || users[1].Score != nil || !users[1].Created.Equal(created) {
+		t.Fatalf("unexpected decode %+v", users[1])
+	}
+
+	var single *mappingUser
+	err = decodeDocs(list[1:], &single)
+	if err != nil || single.Name != "jane Doe" {
+		t.Fatal("decode into pointer failed", single, err)
+	}
+	err = decodeDocs(nil, &single)
+	if err != ErrNotFound {
+		t.Fatal("expected ErrNotFound, got ", err)
+	}
+}
+
+func TestSession_Find(t *testing.T) {
+	DB := newMemoryTest(t)
+	defer DB.Close()
+
+	type user struct {
+		ID    string `json:"_id"`
+		Name  string `json:"name"`
+		Email string `json:"email"`
+		Age   int    `json:"age"`
+	}
+
+	for i, name := range []string{"john Doe", "jane Doe"} {
+		_, err := DB.Table("user").Insert(newMemoryUser(name, name[:4]+"@test.com", 20+i))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var users []user
+	err := DB.Table("user").Where(`{"age": {"$gt": ?}}`, 10).Find(&users)
+	if err != nil || len(users) != 2 || users[1].Name != "jane Doe" || users[1].ID == "" {
+		t.Fatal("Find failed", users, err)
+	}
+
+	var u user
+	err = DB.Table("user").Where(`{"name": ?}`, "jane Doe").First(&u)
+	if err != nil || u.Age != 21 {
+		t.Fatal("First failed", u, err)
+	}
+	err = DB.Table("user").Where(`{"name": ?}`, "nobody").First(&u)
+	if err != ErrNotFound {
+		t.Fatal("expected ErrNotFound, got ", err)
+	}
+}
@@ -0,0 +1,537 @@
+package gorgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cast"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Mongo style query evaluation for the dialects that filter documents in
+// process. Queries are json documents like {"age": {"$gt": ?}, "name": ?},
+// every ? is replaced by the next param.
+
+// bindQuery - replace each ? outside string literals by the json encoding of the next param
+func bindQuery(query string, params ...interface{}) (string, error) {
+	if len(params) == 0 {
+		return query, nil
+	}
+	var buf strings.Builder
+	inString := false
+	escaped := false
+	next := 0
+	for _, r := range query {
+		switch {
+		case escaped:
+			escaped = false
+		case inString && r == '\\':
+			escaped = true
+		case r == '"':
+			inString = !inString
+		case !inString && r == '?':
+			if next >= len(params) {
+				return "", fmt.Errorf("Query has more ? than params: %s", query)
+			}
+			encoded, err := json.Marshal(params[next])
+			if err != nil {
+				return "", err
+			}
+			buf.Write(encoded)
+			next++
+			continue
+		}
+		buf.WriteRune(r)
+	}
+	if next != len(params) {
+		return "", fmt.Errorf("Query has %d ? and %d params: %s", next, len(params), query)
+	}
+	return buf.String(), nil
+}
+
+// parseFilter - decode a json query, binding params to its ? placeholders
+func parseFilter(query string, params ...interface{}) (map[string]interface{}, error) {
+	filter := make(map[string]interface{})
+	if strings.TrimSpace(query) == "" {
+		return filter, nil
+	}
+	bound, err := bindQuery(query, params...)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal([]byte(bound), &filter)
+	if err != nil {
+		return nil, fmt.Errorf("Query parsing error:%v", err)
+	}
+	return filter, nil
+}
+
+// lookupField - value of a dotted path inside the document
+func lookupField(doc map[string]interface{}, path string) (interface{}, bool) {
+	var current interface{} = doc
+	for _, part := range strings.Split(path, ".") {
+		switch v := current.(type) {
+		case map[string]interface{}:
+			value, ok := v[part]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case JSONDoc:
+			value, ok := v[part]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case bson.M:
+			value, ok := v[part]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// matchFilter - true when the document satisfies the mongo style filter
+func matchFilter(doc map[string]interface{}, filter map[string]interface{}) (bool, error) {
+	for key, cond := range filter {
+		var ok bool
+		var err error
+		switch key {
+		case "$and", "$or", "$nor":
+			ok, err = matchLogical(doc, key, cond)
+		default:
+			value, found := lookupField(doc, key)
+			ok, err = matchValue(value, found, cond)
+		}
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func matchLogical(doc map[string]interface{}, op string, cond interface{}) (bool, error) {
+	list, ok := cond.([]interface{})
+	if !ok {
+		return false, fmt.Errorf("%s needs an array", op)
+	}
+	matched := 0
+	for _, item := range list {
+		sub, ok := toFilter(item)
+		if !ok {
+			return false, fmt.Errorf("%s needs an array of documents", op)
+		}
+		res, err := matchFilter(doc, sub)
+		if err != nil {
+			return false, err
+		}
+		if res {
+			matched++
+		}
+	}
+	switch op {
+	case "$and":
+		return matched == len(list), nil
+	case "$or":
+		return matched > 0, nil
+	}
+	return matched == 0, nil
+}
+
+func toFilter(v interface{}) (map[string]interface{}, bool) {
+	switch f := v.(type) {
+	case map[string]interface{}:
+		return f, true
+	case JSONDoc:
+		return f, true
+	case bson.M:
+		return f, true
+	}
+	return nil, false
+}
+
+// isOperatorDoc - true for {"$gt": 1, ...}
+func isOperatorDoc(m map[string]interface{}) bool {
+	if len(m) == 0 {
+		return false
+	}
+	for k := range m {
+		if !strings.HasPrefix(k, "$") {
+			return false
+		}
+	}
+	return true
+}
+
+func matchValue(value interface{}, found bool, cond interface{}) (bool, error) {
+	ops, isMap := toFilter(cond)
+	if !isMap || !isOperatorDoc(ops) {
+		return equalValues(value, cond), nil
+	}
+
+	for op, arg := range ops {
+		var ok bool
+		switch op {
+		case "$eq":
+			ok = equalValues(value, arg)
+		case "$ne":
+			ok = !equalValues(value, arg)
+		case "$gt", "$gte", "$lt", "$lte":
+			c, comparable := compareValues(value, arg)
+			if !found || !comparable {
+				ok = false
+				break
+			}
+			switch op {
+			case "$gt":
+				ok = c > 0
+			case "$gte":
+				ok = c >= 0
+			case "$lt":
+				ok = c < 0
+			case "$lte":
+				ok = c <= 0
+			}
+		case "$in", "$nin":
+			list, isList := arg.([]interface{})
+			if !isList {
+				return false, fmt.Errorf("%s needs an array", op)
+			}
+			in := false
+			for _, item := range list {
+				if equalValues(value, item) {
+					in = true
+					break
+				}
+			}
+			ok = in == (op == "$in")
+		case "$exists":
+			ok = found == cast.ToBool(arg)
+		case "$regex":
+			pattern := cast.ToString(arg)
+			if strings.Contains(cast.ToString(ops["$options"]), "i") {
+				pattern = "(?i)" + pattern
+			}
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return false, err
+			}
+			ok = found && re.MatchString(cast.ToString(value))
+		case "$options":
+			ok = true
+		case "$not":
+			res, err := matchValue(value, found, arg)
+			if err != nil {
+				return false, err
+			}
+			ok = !res
+		default:
+			return false, fmt.Errorf("Query operator %s not supported", op)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// equalValues - equality with numbers compared by value and arrays matching any element
+func equalValues(value interface{}, arg interface{}) bool {
+	if list, ok := value.([]interface{}); ok {
+		if _, argIsList := arg.([]interface{}); !argIsList {
+			for _, item := range list {
+				if equalValues(item, arg) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	if value == nil || arg == nil {
+		return value == nil && arg == nil
+	}
+	if c, ok := compareValues(value, arg); ok {
+		return c == 0
+	}
+	return reflect.DeepEqual(value, arg)
+}
+
+func isNumeric(v interface{}) bool {
+	switch v.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return true
+	}
+	return false
+}
+
+// compareValues - order two values of the same kind, ok is false when they can't be compared
+func compareValues(a interface{}, b interface{}) (int, bool) {
+	switch {
+	case isNumeric(a) && isNumeric(b):
+		fa, fb := cast.ToFloat64(a), cast.ToFloat64(b)
+		switch {
+		case fa < fb:
+			return -1, true
+		case fa > fb:
+			return 1, true
+		}
+		return 0, true
+	}
+
+	ta, aIsTime := a.(time.Time)
+	tb, bIsTime := b.(time.Time)
+	if aIsTime || bIsTime {
+		var err error
+		if !aIsTime {
+			ta, err = cast.ToTimeE(a)
+		}
+		if !bIsTime && err == nil {
+			tb, err = cast.ToTimeE(b)
+		}
+		if err != nil {
+			return 0, false
+		}
+		switch {
+		case ta.Before(tb):
+			return -1, true
+		case ta.After(tb):
+			return 1, true
+		}
+		return 0, true
+	}
+
+	sa, aIsString := a.(string)
+	sb, bIsString := b.(string)
+	if aIsString && bIsString {
+		return strings.Compare(sa, sb), true
+	}
+	ba, aIsBool := a.(bool)
+	bb, bIsBool := b.(bool)
+	if aIsBool && bIsBool {
+		switch {
+		case ba == bb:
+			return 0, true
+		case !ba:
+			return -1, true
+		}
+		return 1, true
+	}
+	return 0, false
+}
+
+// orderTerm - one field of an order clause
+type orderTerm struct {
+	field string
+	desc  bool
+}
+
+// parseOrder - terms of "-field,other" or "field desc, other asc"
+func parseOrder(sorted string) []orderTerm {
+	var terms []orderTerm
+	for _, part := range strings.Split(sorted, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		t := orderTerm{}
+		if strings.HasPrefix(part, "-") {
+			t.desc = true
+			part = part[1:]
+		}
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		t.field = fields[0]
+		if len(fields) > 1 && strings.ToUpper(fields[1]) == "DESC" {
+			t.desc = true
+		}
+		terms = append(terms, t)
+	}
+	return terms
+}
+
+// sortDocs - sort in place by "-field,other" or "field desc, other asc"
+func sortDocs(list []JSONDoc, sorted string) {
+	terms := parseOrder(sorted)
+	if len(terms) == 0 {
+		return
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		for _, t := range terms {
+			a, aFound := lookupField(list[i], t.field)
+			b, bFound := lookupField(list[j], t.field)
+			c := 0
+			switch {
+			case !aFound && !bFound:
+			case !aFound:
+				c = -1
+			case !bFound:
+				c = 1
+			default:
+				c, _ = compareValues(a, b)
+			}
+			if c != 0 {
+				if t.desc {
+					return c > 0
+				}
+				return c < 0
+			}
+		}
+		return false
+	})
+}
+
+// projectDoc - keep only the columns and the id of the document
+func projectDoc(doc JSONDoc, columns []string, idKey string) JSONDoc {
+	if len(columns) == 0 || doc == nil {
+		return doc
+	}
+	projected := JSONDoc{}
+	if v, ok := doc[idKey]; ok {
+		projected[idKey] = v
+	}
+	for _, c := range columns {
+		if v, ok := doc[c]; ok {
+			projected[c] = v
+		}
+	}
+	return projected
+}
+
+// pageDocs - apply offset and limit, limit 0 means no limit
+func pageDocs(list []JSONDoc, offset int, limit int) []JSONDoc {
+	if offset > len(list) {
+		offset = len(list)
+	}
+	if offset > 0 {
+		list = list[offset:]
+	}
+	if limit > 0 && limit < len(list) {
+		list = list[:limit]
+	}
+	return list
+}
+
+// groupDocs - evaluate a mongo style $group stage over the documents
+func groupDocs(list []JSONDoc, query map[string]interface{}) ([]JSONDoc, error) {
+	stage := query
+	if g, ok := toFilter(query["$group"]); ok {
+		stage = g
+	}
+
+	keyOf := func(doc JSONDoc) (interface{}, error) {
+		switch id := stage["_id"].(type) {
+		case nil:
+			return nil, nil
+		case string:
+			v, _ := lookupField(doc, strings.TrimPrefix(id, "$"))
+			return v, nil
+		case map[string]interface{}:
+			key := JSONDoc{}
+			for k, field := range id {
+				key[k], _ = lookupField(doc, strings.TrimPrefix(cast.ToString(field), "$"))
+			}
+			return key, nil
+		}
+		return nil, fmt.Errorf("Invalid group _id %v", stage["_id"])
+	}
+
+	var groups []JSONDoc
+	var members [][]JSONDoc
+	for _, doc := range list {
+		key, err := keyOf(doc)
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for i, g := range groups {
+			if reflect.DeepEqual(g["_id"], key) {
+				members[i] = append(members[i], doc)
+				found = true
+				break
+			}
+		}
+		if !found {
+			groups = append(groups, JSONDoc{"_id": key})
+			members = append(members, []JSONDoc{doc})
+		}
+	}
+
+	for i, g := range groups {
+		for alias, spec := range stage {
+			if alias == "_id" {
+				continue
+			}
+			acc, ok := toFilter(spec)
+			if !ok || len(acc) != 1 {
+				return nil, fmt.Errorf("Invalid accumulator for %s", alias)
+			}
+			for op, arg := range acc {
+				value, err := accumulate(op, arg, members[i])
+				if err != nil {
+					return nil, err
+				}
+				g[alias] = value
+			}
+		}
+	}
+	return groups, nil
+}
+
+func accumulate(op string, arg interface{}, docs []JSONDoc) (interface{}, error) {
+	field, isField := arg.(string)
+	field = strings.TrimPrefix(field, "$")
+	var values []interface{}
+	for _, doc := range docs {
+		if !isField {
+			values = append(values, arg)
+			continue
+		}
+		if v, ok := lookupField(doc, field); ok && v != nil {
+			values = append(values, v)
+		}
+	}
+
+	switch op {
+	case "$count":
+		return len(docs), nil
+	case "$sum", "$avg":
+		sum := 0.0
+		for _, v := range values {
+			sum += cast.ToFloat64(v)
+		}
+		if op == "$sum" {
+			return sum, nil
+		}
+		if len(values) == 0 {
+			return nil, nil
+		}
+		return sum / float64(len(values)), nil
+	case "$min", "$max":
+		var best interface{}
+		for _, v := range values {
+			if best == nil {
+				best = v
+				continue
+			}
+			c, _ := compareValues(v, best)
+			if (op == "$min" && c < 0) || (op == "$max" && c > 0) {
+				best = v
+			}
+		}
+		return best, nil
+	}
+	return nil, fmt.Errorf("Accumulator %s not supported", op)
+}
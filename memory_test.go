@@ -0,0 +1,167 @@
+package gorgo
+
+import (
+	"testing"
+
+	"github.com/spf13/cast"
+)
+
+func newMemoryTest(t *testing.T) *ORM {
+	config := ConfigDB{}
+	config.ModelFile = "model.json"
+	config.Type = "memory"
+
+	DB, err := NewOrm(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return DB
+}
+
+func newMemoryUser(name string, email string, age int) JSONDoc {
+	return JSONDoc{
+		"name":  name,
+		"email": email,
+		"cpf":   "23749817030",
+		"cnpj":  "78470985000106",
+		"age":   age,
+		"teste": "asdfgrreqw653ter",
+	}
+}
+
+func TestMemoryDialect_Create(t *testing.T) {
+	DB := newMemoryTest(t)
+	defer DB.Close()
+
+	for i, name := range []string{"john Doe", "jane Doe", "mary Doe", "bob Doe"} {
+		_, err := DB.Table("user").Insert(newMemoryUser(name, cast.ToString(i)+"@test.com", 20+i*5))
+		if err != nil {
+			t.Fatal("DB Create Error : ", err)
+		}
+	}
+
+	_, err := DB.Table("user").Insert(newMemoryUser("other", "0@test.com", 50))
+	if err == nil {
+		t.Fatal("expected unique key violation")
+	}
+	_, err = DB.Table("user").Insert(newMemoryUser("x", "x@test.com", 50))
+	if err == nil {
+		t.Fatal("expected minlen validation error")
+	}
+
+	list, err := DB.Table("user").Where(`{"age": {"$gte": ?}, "name": {"$regex": ?}}`, 25, "Doe$").Get()
+	if err != nil {
+		t.Fatal("DB GetManyByQuery Error : ", err)
+	}
+	if len(list) != 3 {
+		t.Fatalf("expected 3 users, got %d", len(list))
+	}
+
+	list, err = DB.dialectDB.GetAll("user", 1, 2, Query{Order: "-age"})
+	if err != nil {
+		t.Fatal("DB GetAll Error : ", err)
+	}
+	if len(list) != 2 || list[0]["name"] != "mary Doe" || list[1]["name"] != "jane Doe" {
+		t.Fatalf("unexpected page %v", list)
+	}
+
+	user := list[0]
+	user["name"] = "upd mary"
+	err = DB.Table("user").Update(user)
+	if err != nil {
+		t.Fatal("DB Update Error : ", err)
+	}
+	stored, err := DB.Table("user").GetByID(cast.ToString(user["_id"]))
+	if err != nil || stored["name"] != "upd mary" {
+		t.Fatal("DB GetByID Error : ", stored, err)
+	}
+
+	err = DB.Table("user").DeleteByID(cast.ToString(user["_id"]))
+	if err != nil {
+		t.Fatal("DB Delete Error : ", err)
+	}
+	_, err = DB.Table("user").Insert(newMemoryUser("new Doe", user["email"].(string), 33))
+	if err != nil {
+		t.Fatal("unique key not released on delete: ", err)
+	}
+
+	i, err := DB.dialectDB.CountByWhere("user", Query{Where: `{"$or": [{"age": 20}, {"name": "new Doe"}]}`})
+	if err != nil || i != 2 {
+		t.Fatal("DB CountByWhere Error : ", i, err)
+	}
+	err = DB.dialectDB.DeleteByWhere("user", Query{Where: `{"age": {"$lt": 30}}`})
+	if err != nil {
+		t.Fatal("DB DeleteByWhere Error : ", err)
+	}
+	i, err = DB.Table("user").Count()
+	if err != nil || i != 2 {
+		t.Fatal("DB Count Error : ", i, err)
+	}
+}
+
+func TestMemoryDialect_OrderBySelect(t *testing.T) {
+	DB := newMemoryTest(t)
+	defer DB.Close()
+
+	for i, name := range []string{"john Doe", "jane Doe", "mary Doe"} {
+		_, err := DB.Table("user").Insert(newMemoryUser(name, cast.ToString(i)+"@test.com", 20+i))
+		if err != nil {
+			t.Fatal("DB Create Error : ", err)
+		}
+	}
+
+	list, err := DB.Table("user").Where(`{"age": {"$gt": ?}}`, 20).OrderBy("age desc").Select("name").Get()
+	if err != nil {
+		t.Fatal("DB GetManyByQuery Error : ", err)
+	}
+	if len(list) != 2 || list[0]["name"] != "mary Doe" || len(list[0]) != 2 {
+		t.Fatalf("unexpected result %v", list)
+	}
+
+	// empty terms are skipped
+	list, err = DB.Table("user").OrderBy("-, ,-age").Get()
+	if err != nil {
+		t.Fatal("DB GetManyByQuery Error : ", err)
+	}
+	if len(list) != 3 || list[0]["name"] != "mary Doe" {
+		t.Fatalf("unexpected result %v", list)
+	}
+}
+
+func TestMemoryDialect_TransactionConflict(t *testing.T) {
+	DB := newMemoryTest(t)
+	defer DB.Close()
+
+	dialect := DB.dialectDB.(*MemoryDialect)
+	tx, err := dialect.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tx.Create("user", newMemoryUser("john Doe", "0@test.com", 20))
+	if err != nil {
+		t.Fatal("DB Create Error : ", err)
+	}
+	_, err = DB.Table("user").Insert(newMemoryUser("jane Doe", "1@test.com", 30))
+	if err != nil {
+		t.Fatal("DB Create Error : ", err)
+	}
+	if err := tx.Commit(); err == nil {
+		t.Fatal("expected transaction conflict")
+	}
+	i, err := DB.Table("user").Count()
+	if err != nil || i != 1 {
+		t.Fatal("DB Count Error : ", i, err)
+	}
+}
+
+func TestMemoryDialect_NoTable(t *testing.T) {
+	DB := newMemoryTest(t)
+	defer DB.Close()
+
+	if err := DB.Table("").DeleteByID("1"); err == nil {
+		t.Fatal("expected an error deleting without a table")
+	}
+	if err := DB.Table("").Update(JSONDoc{"_id": "1"}); err == nil {
+		t.Fatal("expected an error updating without a table")
+	}
+}
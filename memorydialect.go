@@ -0,0 +1,513 @@
+package gorgo
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/spf13/cast"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func init() {
+	RegisterDialect("memory", func() Dialect { return &MemoryDialect{} })
+}
+
+//MemoryDialect - dialect keeping every collection in go maps, nothing is
+//written to disk. Queries use the mongo json syntax, ex: {"age": {"$gt": ?}}
+type MemoryDialect struct {
+	Model  *model
+	Config ConfigDB
+
+	mutex  sync.RWMutex
+	tables map[string]*memoryTable
+
+	// version - incremented by every write, Commit fails when the parent
+	// changed since Begin
+	version uint64
+	parent  *MemoryDialect
+	base    uint64
+}
+
+type memoryTable struct {
+	ids     []string
+	docs    map[string]JSONDoc
+	uniques map[string]string
+}
+
+//InitDB  - initialize database
+func (s *MemoryDialect) InitDB(config ConfigDB) error {
+	mod, err := loadModel(config, func(reloaded *model) {
+		s.Model = reloaded
+	})
+	if err != nil {
+		return err
+	}
+	s.Model = mod
+	s.Config = config
+	s.tables = make(map[string]*memoryTable)
+	return nil
+}
+
+func (s *MemoryDialect) dbModel() *model {
+	return s.Model
+}
+
+//CloseDB  - close database, all data is dropped
+func (s *MemoryDialect) CloseDB() error {
+	if s.parent != nil {
+		return fmt.Errorf("Could not close the database inside a transaction")
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.tables = make(map[string]*memoryTable)
+	return nil
+}
+
+//Begin  - open a transaction over a copy of the collections, Commit
+//replaces them and fails when another write happened in the meantime
+func (s *MemoryDialect) Begin() (TxDialect, error) {
+	if s.parent != nil {
+		return nil, fmt.Errorf("Transaction already started")
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	tables := make(map[string]*memoryTable, len(s.tables))
+	for name, t := range s.tables {
+		tables[name] = t.clone()
+	}
+	return &MemoryDialect{Model: s.Model, Config: s.Config, tables: tables, parent: s, base: s.version}, nil
+}
+
+//Commit  - make the writes of the transaction visible
+func (s *MemoryDialect) Commit() error {
+	if s.parent == nil {
+		return fmt.Errorf("No transaction to commit")
+	}
+	p := s.parent
+	s.parent = nil
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.version != s.base {
+		return fmt.Errorf("Transaction conflict, the data changed since Begin")
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	p.tables = s.tables
+	p.version++
+	return nil
+}
+
+//Rollback  - discard the writes of the transaction
+func (s *MemoryDialect) Rollback() error {
+	if s.parent == nil {
+		return fmt.Errorf("No transaction to rollback")
+	}
+	s.parent = nil
+	return nil
+}
+
+func (t *memoryTable) clone() *memoryTable {
+	c := &memoryTable{
+		ids:     append([]string(nil), t.ids...),
+		docs:    make(map[string]JSONDoc, len(t.docs)),
+		uniques: make(map[string]string, len(t.uniques)),
+	}
+	for id, doc := range t.docs {
+		c.docs[id] = copyDoc(doc)
+	}
+	for key, id := range t.uniques {
+		c.uniques[key] = id
+	}
+	return c
+}
+
+func (s *MemoryDialect) table(collection string) *memoryTable {
+	t, ok := s.tables[collection]
+	if !ok {
+		t = &memoryTable{docs: make(map[string]JSONDoc), uniques: make(map[string]string)}
+		s.tables[collection] = t
+	}
+	return t
+}
+
+func copyDoc(doc JSONDoc) JSONDoc {
+	newDoc := make(JSONDoc, len(doc))
+	for k, v := range doc {
+		newDoc[k] = v
+	}
+	return newDoc
+}
+
+// uniqueKeys - unique index keys of the document
+func (s *MemoryDialect) uniqueKeys(collection string, data JSONDoc) ([]string, error) {
+	var keys []string
+	if val, ok := s.Model.Tables[collection]; ok {
+		for _, f := range val.Fields {
+			if f.Unique == true {
+				if data[f.column()] == nil {
+					return nil, fmt.Errorf("Unique field %s, could not be null", f.Name)
+				}
+				keys = append(keys, f.column()+":"+cast.ToString(data[f.column()]))
+			}
+		}
+	}
+	return keys, nil
+}
+
+// filter - documents of the collection matching the query, in insertion order
+func (s *MemoryDialect) filter(collection string, query Query) ([]JSONDoc, error) {
+	q, err := parseFilter(query.Where, query.Params...)
+	if err != nil {
+		return nil, err
+	}
+	q = withCond(q, query.Cond)
+	t, ok := s.tables[collection]
+	if !ok {
+		return []JSONDoc{}, nil
+	}
+	result := []JSONDoc{}
+	for _, id := range t.ids {
+		doc := t.docs[id]
+		res, err := matchFilter(doc, q)
+		if err != nil {
+			return nil, err
+		}
+		if res {
+			result = append(result, copyDoc(doc))
+		}
+	}
+	return result, nil
+}
+
+func (s *MemoryDialect) Count(collection string) (int, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if t, ok := s.tables[collection]; ok {
+		return len(t.ids), nil
+	}
+	return 0, nil
+}
+
+func (s *MemoryDialect) CountByWhere(collection string, query Query) (int, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	list, err := s.filter(collection, query)
+	return len(list), err
+}
+
+func (s *MemoryDialect) Create(collection string, data JSONDoc) (JSONDoc, error) {
+	sid := bson.NewObjectId().Hex()
+	data["_id"] = sid
+
+	err := validateFields(collection, data, s.Model, s.Config.Validations)
+	if err != nil {
+		return data, err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return data, s.insert(collection, data)
+}
+
+//CreateMany  - insert the documents under one write lock, none when one fails
+func (s *MemoryDialect) CreateMany(collection string, docs []JSONDoc) error {
+	for _, doc := range docs {
+		doc["_id"] = bson.NewObjectId().Hex()
+	}
+	err := validateRows(collection, docs, s.Model, s.Config.Validations)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	backup := s.table(collection).clone()
+	for i, doc := range docs {
+		err = s.insert(collection, doc)
+		if err != nil {
+			s.tables[collection] = backup
+			return BulkError{{Index: i, Err: err}}
+		}
+	}
+	return nil
+}
+
+//Upsert  - insert or update the document matching the keys under the write lock
+func (s *MemoryDialect) Upsert(collection string, doc JSONDoc, keys []string, onInsert JSONDoc) (JSONDoc, error) {
+	var conds []Cond
+	for _, key := range keys {
+		conds = append(conds, Eq(key, doc[key]))
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	list, err := s.filter(collection, Query{Cond: And(conds...)})
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		newDoc := copyDoc(doc)
+		for k, v := range onInsert {
+			newDoc[k] = v
+		}
+		newDoc["_id"] = bson.NewObjectId().Hex()
+		err = validateFields(collection, newDoc, s.Model, s.Config.Validations)
+		if err != nil {
+			return nil, err
+		}
+		return newDoc, s.insert(collection, newDoc)
+	}
+
+	stored := copyDoc(list[0])
+	for k, v := range doc {
+		stored[k] = v
+	}
+	err = validateFields(collection, stored, s.Model, s.Config.Validations)
+	if err != nil {
+		return nil, err
+	}
+	return stored, s.replace(collection, stored)
+}
+
+// insert - store a new document, the lock must be held
+func (s *MemoryDialect) insert(collection string, data JSONDoc) error {
+	sid := cast.ToString(data["_id"])
+	uniques, err := s.uniqueKeys(collection, data)
+	if err != nil {
+		return err
+	}
+	t := s.table(collection)
+	for _, key := range uniques {
+		if _, ok := t.uniques[key]; ok {
+			return fmt.Errorf("Unique key violated - key[%s] ", key)
+		}
+	}
+	for _, key := range uniques {
+		t.uniques[key] = sid
+	}
+	t.ids = append(t.ids, sid)
+	t.docs[sid] = copyDoc(data)
+	s.version++
+	return nil
+}
+
+// CreateInterface - insert a struct through Create, writing the generated _id back into it
+func (s *MemoryDialect) CreateInterface(collection string, i interface{}) error {
+	data, err := encodeStruct(i)
+	if err != nil {
+		return err
+	}
+	newDoc, err := s.Create(collection, data)
+	if err != nil {
+		return err
+	}
+	return setStructField(i, "_id", newDoc["_id"])
+}
+
+func (s *MemoryDialect) GetById(collection string, id string, columns ...string) (JSONDoc, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if t, ok := s.tables[collection]; ok {
+		if doc, ok := t.docs[id]; ok {
+			return projectDoc(copyDoc(doc), columns, "_id"), nil
+		}
+	}
+	return nil, fmt.Errorf("Item id[%s] not found", id)
+}
+
+// find - sorted and projected documents matching the query
+func (s *MemoryDialect) find(collection string, q Query) ([]JSONDoc, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	list, err := s.filter(collection, q)
+	if err != nil {
+		return nil, err
+	}
+	sortDocs(list, q.Order)
+	for i, doc := range list {
+		list[i] = projectDoc(doc, q.Columns, "_id")
+	}
+	return list, nil
+}
+
+func (s *MemoryDialect) GetOneByQuery(collection string, q Query) (JSONDoc, error) {
+	list, err := s.find(collection, q)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("Item not found")
+	}
+	return list[0], nil
+}
+
+func (s *MemoryDialect) GetManyByQuery(collection string, q Query) ([]JSONDoc, error) {
+	list, err := s.find(collection, q)
+	if err != nil {
+		return nil, err
+	}
+	return pageDocs(list, q.Offset, q.Limit), nil
+}
+
+func (s *MemoryDialect) GetAll(collection string, offset int, limit int, q Query) ([]JSONDoc, error) {
+	q.Where, q.Params = "", nil
+	list, err := s.find(collection, q)
+	if err != nil {
+		return nil, err
+	}
+	return pageDocs(list, offset, limit), nil
+}
+
+func (s *MemoryDialect) GetAllBySearch(collection string, text string, field string, page int, qtd int, q Query) ([]JSONDoc, error) {
+	re, err := regexp.Compile(text)
+	if err != nil {
+		return nil, err
+	}
+	list, err := s.find(collection, Query{Order: q.Order})
+	if err != nil {
+		return nil, err
+	}
+	result := []JSONDoc{}
+	for _, doc := range list {
+		if v, ok := lookupField(doc, field); ok && re.MatchString(cast.ToString(v)) {
+			result = append(result, projectDoc(doc, q.Columns, "_id"))
+		}
+	}
+	if page < 1 {
+		page = 1
+	}
+	return pageDocs(result, (page-1)*qtd, qtd), nil
+}
+
+func (s *MemoryDialect) Update(collection string, data JSONDoc) error {
+	if data["_id"] == nil {
+		return fmt.Errorf("Field _id could not be null")
+	}
+	err := validateFields(collection, data, s.Model, s.Config.Validations)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.replace(collection, data)
+}
+
+//Patch  - change some fields of the document under the write lock
+func (s *MemoryDialect) Patch(collection string, id string, ops []FieldOp) error {
+	err := validateChanged(collection, changedFields(ops), s.Model, s.Config.Validations)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	doc, ok := s.table(collection).docs[id]
+	if !ok {
+		return fmt.Errorf("Item id[%s] not found", id)
+	}
+	doc = copyDoc(doc)
+	err = applyOps(doc, ops)
+	if err != nil {
+		return err
+	}
+	return s.replace(collection, doc)
+}
+
+// replace - store the new version of a document, the lock must be held
+func (s *MemoryDialect) replace(collection string, data JSONDoc) error {
+	sid := cast.ToString(data["_id"])
+	uniques, err := s.uniqueKeys(collection, data)
+	if err != nil {
+		return err
+	}
+	t := s.table(collection)
+	olddata, ok := t.docs[sid]
+	if !ok {
+		return fmt.Errorf("Item id[%s] not found", sid)
+	}
+	versioned := s.Model.versioned(collection)
+	if versioned && !sameVersion(olddata[versionField], data) {
+		return ErrStaleObject
+	}
+	for _, key := range uniques {
+		if v, ok := t.uniques[key]; ok && v != sid {
+			return fmt.Errorf("Unique key violated - %s ", v)
+		}
+	}
+	// bumped once nothing can fail, the caller retries with its document
+	if versioned {
+		data[versionField] = cast.ToInt64(data[versionField]) + 1
+	}
+	oldUniques, _ := s.uniqueKeys(collection, olddata)
+	for _, key := range oldUniques {
+		delete(t.uniques, key)
+	}
+	for _, key := range uniques {
+		t.uniques[key] = sid
+	}
+	t.docs[sid] = copyDoc(data)
+	s.version++
+	return nil
+}
+
+// remove - drop the document and its unique keys, the lock must be held
+func (s *MemoryDialect) remove(collection string, id string) error {
+	t, ok := s.tables[collection]
+	if !ok {
+		return fmt.Errorf("Item id[%s] not found", id)
+	}
+	if _, ok := t.docs[id]; !ok {
+		return fmt.Errorf("Item id[%s] not found", id)
+	}
+	delete(t.docs, id)
+	s.version++
+	for i, v := range t.ids {
+		if v == id {
+			t.ids = append(t.ids[:i], t.ids[i+1:]...)
+			break
+		}
+	}
+	for key, v := range t.uniques {
+		if v == id {
+			delete(t.uniques, key)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryDialect) Delete(collection string, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.remove(collection, id)
+}
+
+func (s *MemoryDialect) DeleteByWhere(collection string, query Query) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	list, err := s.filter(collection, query)
+	if err != nil {
+		return err
+	}
+	for _, doc := range list {
+		err = s.remove(collection, cast.ToString(doc["_id"]))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetByGroup - evaluate a mongo style $group stage, returning the first group
+func (s *MemoryDialect) GetByGroup(collection string, query map[string]interface{}) (JSONDoc, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	list, err := s.filter(collection, Query{})
+	if err != nil {
+		return nil, err
+	}
+	groups, err := groupDocs(list, query)
+	if err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 {
+		return JSONDoc{}, nil
+	}
+	return groups[0], nil
+}
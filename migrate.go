@@ -0,0 +1,369 @@
+package gorgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cast"
+)
+
+// migrationsTable - table recording the applied migrations, part of every model
+const migrationsTable = "_migrations"
+
+func migrationsModel() table {
+	fields := []*field{}
+	for _, s := range []string{"id,bigint,autoincrement", "version,varchar,unique,required", "name,varchar", "applied_at,varchar"} {
+		f, _ := parseField(s)
+		fields = append(fields, f)
+	}
+	return table{Name: migrationsTable, Fields: fields}
+}
+
+// Migration - versioned change of the database applied once by Migrate, in
+// version order, and undone by Rollback. Versions sort as strings, use fixed
+// width numbers or timestamps, ex: "0001" or "20240131120000". Up and Down
+// run in a transaction when the dialect has them, with the record of the
+// migration in _migrations.
+type Migration struct {
+	Version string
+	Name    string
+	Up      func(tx *Session) error
+	Down    func(tx *Session) error
+}
+
+// MigrationState - a migration known to the ORM or recorded in the database
+type MigrationState struct {
+	Version   string
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// migrationSet - migrations by version, shared by the ORM copies
+type migrationSet struct {
+	mutex sync.RWMutex
+	list  map[string]Migration
+}
+
+// AddMigration - register migrations, a version may be registered only once
+func (d *ORM) AddMigration(migrations ...Migration) error {
+	if d.migrations == nil {
+		d.migrations = &migrationSet{}
+	}
+	set := d.migrations
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+	if set.list == nil {
+		set.list = make(map[string]Migration)
+	}
+	for _, m := range migrations {
+		if m.Version == "" || m.Up == nil {
+			return fmt.Errorf("Migration %q needs a version and an up function", m.Name)
+		}
+		if _, dup := set.list[m.Version]; dup {
+			return fmt.Errorf("Migration version %s registered twice", m.Version)
+		}
+		set.list[m.Version] = m
+	}
+	return nil
+}
+
+// LoadMigrations - register the migration files of dir, named
+// <version>_<name>.up.sql and <version>_<name>.down.sql, or .json. A sql file
+// holds statements ended by ";" at the end of a line, for the sql dialects. A
+// json file holds a list of portable operations:
+//
+//	[{"op": "insert", "table": "user", "doc": {"name": "admin"}},
+//	 {"op": "update", "table": "user", "where": {"role": null}, "set": {"role": "user"}},
+//	 {"op": "delete", "table": "user", "where": {"name": "guest"}},
+//	 {"op": "rename", "table": "user", "field": "fone", "to": "phone"}]
+//
+// where matches the fields equal to the given values, rename moves a field of
+// the documents and is meant for the schemaless dialects.
+func (d *ORM) LoadMigrations(dir string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	byVersion := map[string]*Migration{}
+	var versions []string
+	for _, file := range files {
+		name := file.Name()
+		ext := filepath.Ext(name)
+		if file.IsDir() || ext != ".sql" && ext != ".json" {
+			continue
+		}
+		base := strings.TrimSuffix(name, ext)
+		direction := filepath.Ext(base)
+		if direction != ".up" && direction != ".down" {
+			continue
+		}
+		base = strings.TrimSuffix(base, direction)
+		parts := strings.SplitN(base, "_", 2)
+		m, ok := byVersion[parts[0]]
+		if !ok {
+			m = &Migration{Version: parts[0]}
+			if len(parts) > 1 {
+				m.Name = parts[1]
+			}
+			byVersion[parts[0]] = m
+			versions = append(versions, parts[0])
+		}
+
+		content, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		fn, err := migrationFile(ext, content)
+		if err != nil {
+			return fmt.Errorf("Migration %s: %v", name, err)
+		}
+		if direction == ".up" {
+			m.Up = fn
+		} else {
+			m.Down = fn
+		}
+	}
+
+	sort.Strings(versions)
+	var migrations []Migration
+	for _, version := range versions {
+		migrations = append(migrations, *byVersion[version])
+	}
+	return d.AddMigration(migrations...)
+}
+
+// migrationFile - function running the statements or operations of a file
+func migrationFile(ext string, content []byte) (func(tx *Session) error, error) {
+	if ext == ".sql" {
+		var stmts []string
+		for _, stmt := range strings.Split(string(content), ";\n") {
+			stmt = strings.TrimSuffix(strings.TrimSpace(stmt), ";")
+			if stmt != "" {
+				stmts = append(stmts, stmt)
+			}
+		}
+		return func(tx *Session) error {
+			execer, ok := tx.orm.dialectDB.(sqlExecer)
+			if !ok {
+				return fmt.Errorf("sql migrations need a sql dialect")
+			}
+			for _, stmt := range stmts {
+				err := execer.execStatement(stmt)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}, nil
+	}
+
+	var ops []migrationOp
+	err := json.Unmarshal(content, &ops)
+	if err != nil {
+		return nil, err
+	}
+	return func(tx *Session) error {
+		for _, op := range ops {
+			err := op.run(tx)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
+}
+
+// sqlExecer - dialect running raw statements, for the sql migration files
+type sqlExecer interface {
+	execStatement(stmt string) error
+}
+
+// tableSyncer - dialect whose tables must be created before use, the sql ones
+type tableSyncer interface {
+	syncTable(name string, mode string) ([]SchemaChange, error)
+}
+
+// migrationOp - operation of a json migration file
+type migrationOp struct {
+	Op    string
+	Table string
+	Doc   JSONDoc
+	Where map[string]interface{}
+	Set   JSONDoc
+	Field string
+	To    string
+}
+
+func (op migrationOp) run(tx *Session) error {
+	if op.Table == "" {
+		return fmt.Errorf("Migration %s needs a table", op.Op)
+	}
+	var where []Cond
+	for _, k := range sortMap(op.Where) {
+		if op.Where[k] == nil {
+			where = append(where, IsNull(k))
+		} else {
+			where = append(where, Eq(k, op.Where[k]))
+		}
+	}
+
+	session := tx.Table(op.Table)
+	if len(where) > 0 {
+		session.Where(And(where...))
+	}
+
+	switch op.Op {
+	case "insert":
+		_, err := session.Insert(op.Doc)
+		return err
+	case "delete":
+		return session.DeleteByWhere()
+	case "update", "rename":
+		list, err := session.Get()
+		if err != nil {
+			return err
+		}
+		for _, doc := range list {
+			if op.Op == "rename" {
+				value, ok := doc[op.Field]
+				if !ok {
+					continue
+				}
+				delete(doc, op.Field)
+				doc[op.To] = value
+			}
+			for k, v := range op.Set {
+				doc[k] = v
+			}
+			err = tx.Table(op.Table).Update(doc)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("Unknown migration op %q", op.Op)
+}
+
+// MigrationStatus - the registered migrations and those recorded in the
+// database, in version order
+func (d *ORM) MigrationStatus() ([]MigrationState, error) {
+	if syncer, ok := d.dialectDB.(tableSyncer); ok {
+		_, err := syncer.syncTable(migrationsTable, SyncCreate)
+		if err != nil {
+			return nil, err
+		}
+	}
+	records, err := d.Table(migrationsTable).Where(Not(IsNull("version"))).Get()
+	if err != nil {
+		return nil, err
+	}
+	states := map[string]*MigrationState{}
+	for _, m := range d.registeredMigrations() {
+		states[m.Version] = &MigrationState{Version: m.Version, Name: m.Name}
+	}
+	for _, record := range records {
+		version := cast.ToString(record["version"])
+		state, ok := states[version]
+		if !ok {
+			state = &MigrationState{Version: version, Name: cast.ToString(record["name"])}
+			states[version] = state
+		}
+		state.Applied = true
+		state.AppliedAt, _ = time.Parse(time.RFC3339, cast.ToString(record["applied_at"]))
+	}
+
+	var list []MigrationState
+	for _, state := range states {
+		list = append(list, *state)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+func (d *ORM) registeredMigrations() map[string]Migration {
+	list := map[string]Migration{}
+	if d.migrations == nil {
+		return list
+	}
+	d.migrations.mutex.RLock()
+	defer d.migrations.mutex.RUnlock()
+	for version, m := range d.migrations.list {
+		list[version] = m
+	}
+	return list
+}
+
+// Migrate - apply the registered migrations missing from the database, in
+// version order, stopping at the first error. Returns the applied versions.
+func (d *ORM) Migrate() ([]string, error) {
+	states, err := d.MigrationStatus()
+	if err != nil {
+		return nil, err
+	}
+	migrations := d.registeredMigrations()
+	var applied []string
+	for _, state := range states {
+		m, ok := migrations[state.Version]
+		if state.Applied || !ok {
+			continue
+		}
+		err = d.atomic(func(txOrm *ORM) error {
+			err := m.Up(txOrm.NewSession())
+			if err != nil {
+				return err
+			}
+			_, err = txOrm.Table(migrationsTable).Insert(JSONDoc{
+				"version":    m.Version,
+				"name":       m.Name,
+				"applied_at": time.Now().UTC().Format(time.RFC3339),
+			})
+			return err
+		})
+		if err != nil {
+			return applied, fmt.Errorf("Migration %s %s: %v", m.Version, m.Name, err)
+		}
+		applied = append(applied, m.Version)
+	}
+	return applied, nil
+}
+
+// Rollback - undo the last n applied migrations, the most recent version
+// first. Returns the rolled back versions.
+func (d *ORM) Rollback(n int) ([]string, error) {
+	states, err := d.MigrationStatus()
+	if err != nil {
+		return nil, err
+	}
+	migrations := d.registeredMigrations()
+	var rolled []string
+	for i := len(states) - 1; i >= 0 && len(rolled) < n; i-- {
+		if !states[i].Applied {
+			continue
+		}
+		version := states[i].Version
+		m, ok := migrations[version]
+		if !ok || m.Down == nil {
+			return rolled, fmt.Errorf("Migration %s %s has no down to roll back", version, states[i].Name)
+		}
+		err = d.atomic(func(txOrm *ORM) error {
+			err := m.Down(txOrm.NewSession())
+			if err != nil {
+				return err
+			}
+			return txOrm.Table(migrationsTable).Where(Eq("version", version)).DeleteByWhere()
+		})
+		if err != nil {
+			return rolled, fmt.Errorf("Migration %s %s: %v", m.Version, m.Name, err)
+		}
+		rolled = append(rolled, version)
+	}
+	return rolled, nil
+}
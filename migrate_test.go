@@ -0,0 +1,123 @@
+package gorgo
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestORM_Migrate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	modelFile := filepath.Join(dir, "model.json")
+	err = ioutil.WriteFile(modelFile, []byte(sqliteTestModel), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"0002_admin.up.json":   `[{"op": "insert", "table": "user", "doc": {"name": "admin", "email": "admin@test.com", "age": 40}}]`,
+		"0002_admin.down.json": `[{"op": "delete", "table": "user", "where": {"name": "admin"}}]`,
+		"0003_ages.up.json":    `[{"op": "update", "table": "user", "where": {"age": null}, "set": {"age": 18}}]`,
+	}
+	migrationsDir := filepath.Join(dir, "migrations")
+	os.Mkdir(migrationsDir, 0755)
+	for name, content := range files {
+		err = ioutil.WriteFile(filepath.Join(migrationsDir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, dialect := range []string{"memory", "localdb", "sqlite"} {
+		config := ConfigDB{}
+		config.ModelFile = modelFile
+		config.Type = dialect
+		config.SyncDB = SyncCreate
+		config.Server = filepath.Join(dir, dialect+".db")
+		DB, err := NewOrm(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = DB.AddMigration(Migration{
+			Version: "0001",
+			Name:    "guest",
+			Up: func(tx *Session) error {
+				_, err := tx.Table("user").Insert(JSONDoc{"name": "guest", "email": "guest@test.com"})
+				return err
+			},
+			Down: func(tx *Session) error {
+				return tx.Table("user").Where(Eq("name", "guest")).DeleteByWhere()
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = DB.LoadMigrations(migrationsDir)
+		if err != nil {
+			t.Fatal(dialect, " LoadMigrations Error : ", err)
+		}
+
+		applied, err := DB.Migrate()
+		if err != nil || !reflect.DeepEqual(applied, []string{"0001", "0002", "0003"}) {
+			t.Fatal(dialect, " Migrate Error : ", applied, err)
+		}
+		applied, err = DB.Migrate()
+		if err != nil || len(applied) != 0 {
+			t.Fatal(dialect, " migrations applied twice : ", applied, err)
+		}
+		guest, err := DB.Table("user").Where(Eq("name", "guest")).Get()
+		if err != nil || len(guest) != 1 || guest[0]["age"] == nil {
+			t.Fatal(dialect, " backfill Error : ", guest, err)
+		}
+
+		// 0003 has no down file
+		rolled, err := DB.Rollback(1)
+		if err == nil || len(rolled) != 0 {
+			t.Fatal(dialect, " expected rollback error : ", rolled, err)
+		}
+		err = DB.AddMigration(Migration{
+			Version: "0004",
+			Name:    "broken",
+			Up: func(tx *Session) error {
+				_, err := tx.Table("user").Insert(JSONDoc{"name": "broken", "email": "broken@test.com"})
+				if err != nil {
+					return err
+				}
+				return fmt.Errorf("failed")
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = DB.Migrate()
+		if err == nil {
+			t.Fatal(dialect, " expected migration error")
+		}
+		i, err := DB.Table("user").Count()
+		if err != nil || i != 2 {
+			t.Fatal(dialect, " failed migration not rolled back : ", i, err)
+		}
+
+		status, err := DB.MigrationStatus()
+		if err != nil || len(status) != 4 || !status[2].Applied || status[3].Applied || status[0].AppliedAt.IsZero() {
+			t.Fatal(dialect, " MigrationStatus Error : ", status, err)
+		}
+		DB.dialectDB.DeleteByWhere(migrationsTable, Query{Cond: Eq("version", "0003")})
+		rolled, err = DB.Rollback(2)
+		if err != nil || !reflect.DeepEqual(rolled, []string{"0002", "0001"}) {
+			t.Fatal(dialect, " Rollback Error : ", rolled, err)
+		}
+		i, err = DB.Table("user").Count()
+		if err != nil || i != 0 {
+			t.Fatal(dialect, " Rollback Error : ", i, err)
+		}
+		DB.Close()
+	}
+}
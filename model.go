@@ -1,9 +1,13 @@
 package gorgo
 
 import (
+	"encoding/json"
 	"github.com/rgobbo/fileutils"
+	"github.com/rgobbo/fsmodify"
 	"strings"
 	"fmt"
+	"log"
+	"sort"
 	"github.com/spf13/cast"
 )
 
@@ -15,13 +19,38 @@ type model struct {
 type table struct {
 	Name string
 	Fields []*field
+	// SoftDelete - deletes set the deletedField timestamp instead of removing the record
+	SoftDelete bool
+	// Stamps - timestamps and audit fields of the table, nil when the model
+	// does not configure them
+	Stamps *stamps
+	// Versioned - updates check and increment the versionField of the record
+	Versioned bool
 }
 
+// stamps - fields the ORM sets on insert and update, an empty name is not set
+type stamps struct {
+	Created   string
+	Updated   string
+	CreatedBy string
+	UpdatedBy string
+}
+
+// defaultStamps - stamps of the tables that do not configure them
+var defaultStamps = stamps{Created: "_created", Updated: "_updated", CreatedBy: "_created_by", UpdatedBy: "_updated_by"}
+
+// deletedField - timestamp of the soft deleted records
+const deletedField = "_deleted"
+
+// versionField - version of the records of the versioned tables, incremented by each update
+const versionField = "_version"
+
 type field struct {
 	Name string
 	Type string
 	Autoincrement bool
 	Unique bool
+	Index bool
 	Default interface{}
 	Alias string
 	Maxlen int
@@ -38,6 +67,11 @@ type modelConfig struct {
 type tableConfig struct {
 	Name string
 	Fields []string
+	SoftDelete bool
+	Versioned bool
+	// Timestamps - true, false or the field names, ex:
+	// {"created": "created_at", "updated": "updated_at", "createdBy": "", "updatedBy": ""}
+	Timestamps json.RawMessage
 }
 
 func (m* model) LoadFile(path string) error{
@@ -61,13 +95,156 @@ func (m* model) LoadFile(path string) error{
 			fields = append(fields,newField)
 		}
 		newTable.Fields = fields
+		newTable.SoftDelete = t.SoftDelete
+		if t.SoftDelete && !newTable.hasField(deletedField) {
+			newTable.Fields = append(newTable.Fields, &field{Name: deletedField, Type: "date"})
+		}
+		newTable.Versioned = t.Versioned
+		if t.Versioned && !newTable.hasField(versionField) {
+			newTable.Fields = append(newTable.Fields, &field{Name: versionField, Type: "bigint"})
+		}
+		newTable.Stamps, err = parseStamps(t.Timestamps)
+		if err != nil {
+			return fmt.Errorf("Table %s timestamps: %v", t.Name, err)
+		}
+		if newTable.Stamps != nil {
+			for _, f := range []*field{
+				{Name: newTable.Stamps.Created, Type: "date"},
+				{Name: newTable.Stamps.Updated, Type: "date"},
+				{Name: newTable.Stamps.CreatedBy, Type: "varchar"},
+				{Name: newTable.Stamps.UpdatedBy, Type: "varchar"},
+			} {
+				if f.Name != "" && !newTable.hasField(f.Name) {
+					newTable.Fields = append(newTable.Fields, f)
+				}
+			}
+		}
 		tables[t.Name] = newTable
 	}
+	tables[migrationsTable] = migrationsModel()
 	m.Tables = tables
 
 	return nil
 }
 
+// newModel - model of the builtin tables, used when there is no model file
+func newModel() *model {
+	return &model{Tables: map[string]table{migrationsTable: migrationsModel()}}
+}
+
+// loadModel - load config.ModelFile, calling onReload with the new model
+// every time the file changes when WatchInterval is set
+func loadModel(config ConfigDB, onReload func(*model)) (*model, error) {
+	if config.ModelFile == "" {
+		return newModel(), nil
+	}
+	m := new(model)
+	err := m.LoadFile(config.ModelFile)
+	if err != nil {
+		return nil, err
+	}
+	if config.WatchInterval > 0 {
+		go fsmodify.NewWatcher(config.ModelFile, "", config.WatchInterval, func(filename string) {
+			reloaded := new(model)
+			err := reloaded.LoadFile(config.ModelFile)
+			if err != nil {
+				log.Println(err)
+				return
+			}
+			onReload(reloaded)
+		})
+	}
+	return m, nil
+}
+
+// primaryKey - name of the autoincrement field of the table, or def when
+// the model does not declare one
+func (m *model) primaryKey(tableName string, def string) string {
+	if t, ok := m.Tables[tableName]; ok {
+		for _, f := range t.Fields {
+			if f.Autoincrement {
+				return f.Name
+			}
+		}
+	}
+	return def
+}
+
+// versioned - true when the updates of the table use optimistic locking
+func (m *model) versioned(tableName string) bool {
+	t, ok := m.Tables[tableName]
+	return ok && t.Versioned
+}
+
+// sameVersion - true when the stored version is the one the update was read with
+func sameVersion(stored interface{}, data JSONDoc) bool {
+	return cast.ToInt64(stored) == cast.ToInt64(data[versionField])
+}
+
+// tableNames - names of the model tables in sorted order
+func (m *model) tableNames() []string {
+	var names []string
+	for name := range m.Tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseStamps - stamps of the timestamps option of a table, nil when absent
+func parseStamps(raw json.RawMessage) (*stamps, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var enabled bool
+	if json.Unmarshal(raw, &enabled) == nil {
+		if enabled {
+			st := defaultStamps
+			return &st, nil
+		}
+		return &stamps{}, nil
+	}
+	var names map[string]string
+	err := json.Unmarshal(raw, &names)
+	if err != nil {
+		return nil, err
+	}
+	st := defaultStamps
+	for key, name := range names {
+		switch key {
+		case "created":
+			st.Created = name
+		case "updated":
+			st.Updated = name
+		case "createdBy":
+			st.CreatedBy = name
+		case "updatedBy":
+			st.UpdatedBy = name
+		default:
+			return nil, fmt.Errorf("unknown field %q", key)
+		}
+	}
+	return &st, nil
+}
+
+// hasField - true when the table declares the field
+func (t table) hasField(name string) bool {
+	for _, f := range t.Fields {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// column - name the field is stored under, the alias when it has one
+func (f *field) column() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
 func parseField (str string) (*field, error) {
 	newField := &field{}
 	parts := strings.Split(str,",")
@@ -79,8 +256,7 @@ func parseField (str string) (*field, error) {
 		if i == 0 {
 			newField.Name = s
 		} else if i == 1 {
-			fieldType := strings.ToLower(s)
-			fieldType = strings.Trim(s," ")
+			fieldType := strings.ToLower(strings.Trim(s," "))
 			switch fieldType {
 			case "string" :
 				newField.Type = fieldType
@@ -108,6 +284,9 @@ func parseField (str string) (*field, error) {
 			if slc == "unique" {
 				newField.Unique = true
 			}
+			if slc == "index" {
+				newField.Index = true
+			}
 			if strings.HasPrefix(slc,"minlen") {
 				sints := strings.Split(slc,"=")
 				i, err := cast.ToIntE(strings.Trim(sints[1]," "))
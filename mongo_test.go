@@ -0,0 +1,104 @@
+package gorgo
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// passedContext - context whose deadline passed before its timer fired
+type passedContext struct {
+	context.Context
+}
+
+func (passedContext) Deadline() (time.Time, bool) {
+	return time.Now().Add(-time.Millisecond), true
+}
+
+func TestMongoDialect_PassedDeadline(t *testing.T) {
+	m := &MongoDialect{ctx: passedContext{context.Background()}}
+	_, err := m.copySession()
+	if err != context.DeadlineExceeded {
+		t.Fatal("expected the deadline exceeded : ", err)
+	}
+}
+
+// newMongoTest - ORM over the mongo server of GORGO_MONGO, localhost by
+// default, the test is skipped when it is not reachable
+func newMongoTest(t *testing.T) *ORM {
+	server := os.Getenv("GORGO_MONGO")
+	if server == "" {
+		server = "localhost:27017"
+	}
+	ss, err := mgo.DialWithTimeout(server, time.Second)
+	if err != nil {
+		t.Skip("mongo server not reachable: ", err)
+	}
+	ss.Close()
+
+	config := ConfigDB{}
+	config.ModelFile = "model.json"
+	config.Type = "mongo"
+	config.Server = server
+	config.Database = "gorgo_test"
+	DB, err := NewOrm(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return DB
+}
+
+func TestMongoDialect_AfterInsertID(t *testing.T) {
+	DB := newMongoTest(t)
+	defer DB.Close()
+	defer DB.dialectDB.(*MongoDialect).Session.DB("gorgo_test").DropDatabase()
+
+	var hookID interface{}
+	DB.OnAfterInsert("user", func(s *Session, doc JSONDoc) error {
+		hookID = doc["_id"]
+		return nil
+	})
+	user, err := DB.Table("user").Insert(newMemoryUser("john Doe", "jd@test.com", 30))
+	if err != nil {
+		t.Fatal("DB Create Error : ", err)
+	}
+	id, ok := hookID.(bson.ObjectId)
+	if !ok || id != user["_id"] {
+		t.Fatal("expected the hook to see the id : ", hookID, user["_id"])
+	}
+	stored, err := DB.Table("user").GetByID(id.Hex())
+	if err != nil || stored["name"] != "john Doe" {
+		t.Fatal("DB GetByID Error : ", stored, err)
+	}
+}
+
+func TestMongoDialect_CreateManyValidation(t *testing.T) {
+	m := &MongoDialect{Model: new(model), Config: ConfigDB{Validations: GetFunctions()}}
+	err := m.Model.LoadFile("model.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bad := newMemoryUser("john Doe", "not an email", 30)
+	// validated before reaching the server, there is none here
+	err = m.CreateMany("user", []JSONDoc{newMemoryUser("jane Doe", "jane@test.com", 20), bad})
+	bulkErr, ok := err.(BulkError)
+	if !ok || len(bulkErr) != 1 || bulkErr[0].Index != 1 {
+		t.Fatal("expected the validation error of row 1 : ", err)
+	}
+}
+
+func TestMongoDialect_PatchValidation(t *testing.T) {
+	m := &MongoDialect{Model: new(model), Config: ConfigDB{Validations: GetFunctions()}}
+	err := m.Model.LoadFile("model.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Patch("user", bson.NewObjectId().Hex(), []FieldOp{Set("email", "not an email")})
+	if err == nil {
+		t.Fatal("expected validation error on a patched field")
+	}
+}
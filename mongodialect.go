@@ -1,23 +1,16 @@
 package gorgo
 
 import (
+	"context"
 	"crypto/tls"
-	"encoding/json"
 	"fmt"
 	"net"
-	"reflect"
 	"time"
 
-	"github.com/spf13/cast"
-
-	"log"
-
-	"strings"
-
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 
-	"github.com/rgobbo/fsmodify"
+	"github.com/spf13/cast"
 )
 
 //MySQLDialect - dialect for mysql database
@@ -25,28 +18,24 @@ type MongoDialect struct {
 	Session *mgo.Session
 	DBName  string
 	Model   *model
+	Config  ConfigDB
+
+	// ctx - context of a dialect returned by WithContext
+	ctx context.Context
 }
 
-func (m *MongoDialect) InitDB(config ConfigDB) error {
+func init() {
+	RegisterDialect("mongo", func() Dialect { return &MongoDialect{} })
+}
 
-	if config.ModelFile != "" {
-		m.Model = new(model)
-		err := m.Model.LoadFile(config.ModelFile)
-		if err != nil {
-			return err
-		}
-		if config.WatchInterval > 0 {
-			go fsmodify.NewWatcher(config.ModelFile, "", config.WatchInterval, func(filename string) {
-				m.Model = new(model)
-				err := m.Model.LoadFile(config.ModelFile)
-				if err != nil {
-					log.Println(err)
-				}
-			})
-		}
-	} else {
-		m.Model = new(model)
+func (m *MongoDialect) InitDB(config ConfigDB) error {
+	mod, err := loadModel(config, func(reloaded *model) {
+		m.Model = reloaded
+	})
+	if err != nil {
+		return err
 	}
+	m.Model = mod
 
 	var servers []string
 	if len(config.Servers) > 0 {
@@ -81,168 +70,478 @@ func (m *MongoDialect) InitDB(config ConfigDB) error {
 	m.Session = session
 
 	m.DBName = dbname
+	m.Config = config
 	return nil
 }
 
+func (m *MongoDialect) dbModel() *model {
+	return m.Model
+}
+
 //CloseDB  - close database
 func (m *MongoDialect) CloseDB() error {
 	m.Session.Close()
 	return nil
 }
 
-func (m *MongoDialect) Count(collection string) (int, error) {
+//WithContext  - copy of the dialect checking ctx before each call and
+//bounding the socket timeout by its deadline, mgo has no cancellation
+func (m *MongoDialect) WithContext(ctx context.Context) Dialect {
+	return &MongoDialect{Session: m.Session, DBName: m.DBName, Model: m.Model, Config: m.Config, ctx: ctx}
+}
+
+// copySession - session for one call, failing when the context is done
+func (m *MongoDialect) copySession() (*mgo.Session, error) {
+	if m.ctx != nil {
+		if err := m.ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+	var timeout time.Duration
+	if m.ctx != nil {
+		if deadline, ok := m.ctx.Deadline(); ok {
+			// a zero socket timeout means none for mgo
+			timeout = time.Until(deadline)
+			if timeout <= 0 {
+				return nil, context.DeadlineExceeded
+			}
+		}
+	}
 	ss := m.Session.Copy()
+	if timeout > 0 {
+		ss.SetSocketTimeout(timeout)
+	}
+	return ss, nil
+}
+
+//Begin  - multi document transactions need the session api of mongodb 4.0,
+//the mgo driver does not implement it, ErrTxNotSupported is returned
+func (m *MongoDialect) Begin() (TxDialect, error) {
+	return nil, ErrTxNotSupported
+}
+
+func (m *MongoDialect) Count(collection string) (int, error) {
+	ss, err := m.copySession()
+	if err != nil {
+		return 0, err
+	}
 	defer ss.Close()
 	c := ss.DB(m.DBName).C(collection)
 	return c.Count()
 }
 
-func (m *MongoDialect) CountByWhere(collection string, query string) (int, error) {
-	ss := m.Session.Copy()
+func (m *MongoDialect) CountByWhere(collection string, q Query) (int, error) {
+	ss, err := m.copySession()
+	if err != nil {
+		return 0, err
+	}
 	defer ss.Close()
 	c := ss.DB(m.DBName).C(collection)
-	q := make(map[string]interface{})
-	err := json.Unmarshal([]byte(query), &q)
+	filter, err := m.filter(q)
 	if err != nil {
 		return 0, err
 	}
-	return c.Find(q).Count()
+	return c.Find(filter).Count()
 }
 
 func (m *MongoDialect) Create(collection string, json JSONDoc) (JSONDoc, error) {
-	ss := m.Session.Copy()
+	ss, err := m.copySession()
+	if err != nil {
+		return nil, err
+	}
 	defer ss.Close()
 	c := ss.DB(m.DBName).C(collection)
+	// the id is generated here, mgo does not return the one of the server
+	if json["_id"] == nil {
+		json["_id"] = bson.NewObjectId()
+	}
 	return json, c.Insert(json)
 }
 
 func (m *MongoDialect) CreateInterface(collection string, i interface{}) error {
-	ss := m.Session.Copy()
+	ss, err := m.copySession()
+	if err != nil {
+		return err
+	}
 	defer ss.Close()
 	c := ss.DB(m.DBName).C(collection)
 	return c.Insert(i)
 }
 
-func (m *MongoDialect) GetById(collection string, id string) (JSONDoc, error) {
+func (m *MongoDialect) GetById(collection string, id string, columns ...string) (JSONDoc, error) {
 	var data JSONDoc
-	ss := m.Session.Copy()
+	ss, err := m.copySession()
+	if err != nil {
+		return nil, err
+	}
 	defer ss.Close()
 	c := ss.DB(m.DBName).C(collection)
 	if !bson.IsObjectIdHex(id) {
 		return data, fmt.Errorf("Mongo ObjectID is invalid")
 	}
-	err := c.Find(bson.M{"_id": bson.ObjectIdHex(id)}).One(&data)
+	err = m.find(c, bson.M{"_id": bson.ObjectIdHex(id)}, Query{Columns: columns}).One(&data)
 	return data, err
 }
 
-func (m *MongoDialect) GetOneByQuery(collection string, query string) (JSONDoc, error) {
+// find - mgo query with the order and the projection of q
+func (m *MongoDialect) find(c *mgo.Collection, filter interface{}, q Query) *mgo.Query {
+	query := c.Find(filter)
+	if sorted := mongoSort(q.Order); len(sorted) > 0 {
+		query = query.Sort(sorted...)
+	}
+	if len(q.Columns) > 0 {
+		fields := bson.M{}
+		for _, col := range q.Columns {
+			fields[col] = 1
+		}
+		query = query.Select(fields)
+	}
+	return query
+}
+
+// mongoSort - mgo sort keys of "-field,other" or "field desc, other asc"
+func mongoSort(sorted string) []string {
+	var keys []string
+	for _, t := range parseOrder(sorted) {
+		if t.desc {
+			keys = append(keys, "-"+t.field)
+		} else {
+			keys = append(keys, t.field)
+		}
+	}
+	return keys
+}
+
+func (m *MongoDialect) GetOneByQuery(collection string, q Query) (JSONDoc, error) {
 	var data JSONDoc
-	ss := m.Session.Copy()
+	ss, err := m.copySession()
+	if err != nil {
+		return nil, err
+	}
 	defer ss.Close()
 	c := ss.DB(m.DBName).C(collection)
 
-	var qjson map[string]interface{}
-	err := json.Unmarshal([]byte(query), &qjson)
+	qjson, err := m.filter(q)
 	if err != nil {
-		return data, fmt.Errorf("Query parsing error:%v", err)
+		return data, err
 	}
 
-	err = c.Find(qjson).One(&data)
+	err = m.find(c, qjson, q).One(&data)
 	return data, err
 }
 
-func (m *MongoDialect) GetManyByQuery(collection string, query string, params ...interface{}) ([]JSONDoc, error) {
+func (m *MongoDialect) GetManyByQuery(collection string, q Query) ([]JSONDoc, error) {
 	var data []JSONDoc
-	ss := m.Session.Copy()
+	ss, err := m.copySession()
+	if err != nil {
+		return nil, err
+	}
 	defer ss.Close()
 	c := ss.DB(m.DBName).C(collection)
 
-	squery := m.parseQuery(query, params...)
-	log.Println(squery)
-
-	var qjson map[string]interface{}
-	err := json.Unmarshal([]byte(squery), &qjson)
+	qjson, err := m.filter(q)
 	if err != nil {
-		return data, fmt.Errorf("Query parsing error:%v", err)
+		return data, err
 	}
-	err = c.Find(qjson).All(&data)
+	err = m.find(c, qjson, q).Skip(q.Offset).Limit(q.Limit).All(&data)
 	return data, err
 }
 
-func (m *MongoDialect) parseQuery(query string, params ...interface{}) string {
-	for _, p := range params {
-		if reflect.TypeOf(p).String() == "string" {
-			ps := `"` + p.(string) + `"`
-			query = strings.Replace(query, "?", ps, 1)
-		} else {
-			query = strings.Replace(query, "?", cast.ToString(p), 1)
+//Stream  - documents of the query read one by one from a mgo iterator
+func (m *MongoDialect) Stream(collection string, q Query) (*Rows, error) {
+	ss, err := m.copySession()
+	if err != nil {
+		return nil, err
+	}
+	qjson, err := m.filter(q)
+	if err != nil {
+		ss.Close()
+		return nil, err
+	}
+	iter := m.find(ss.DB(m.DBName).C(collection), qjson, q).Skip(q.Offset).Limit(q.Limit).Iter()
+	next := func() (JSONDoc, error) {
+		var doc JSONDoc
+		if iter.Next(&doc) {
+			return doc, nil
 		}
+		return nil, iter.Err()
+	}
+	return newRows(next, func() error {
+		defer ss.Close()
+		return iter.Close()
+	}), nil
+}
 
+// filter - mongo filter of the json query and the condition
+func (m *MongoDialect) filter(q Query) (map[string]interface{}, error) {
+	qjson, err := parseFilter(q.Where, q.Params...)
+	if err != nil {
+		return nil, err
 	}
-	return query
+	return withCond(qjson, q.Cond), nil
 }
 
-func (m *MongoDialect) GetAll(collection string, page int, qtd int, sorted string) ([]JSONDoc, error) {
-	ss := m.Session.Copy()
+func (m *MongoDialect) GetAll(collection string, offset int, limit int, q Query) ([]JSONDoc, error) {
+	ss, err := m.copySession()
+	if err != nil {
+		return nil, err
+	}
 	defer ss.Close()
 	c := ss.DB(m.DBName).C(collection)
 
-	var err error
 	var result []JSONDoc
-	if sorted != "" {
-		err = c.Find(bson.M{}).Sort(sorted).Skip((page - 1) * qtd).Limit(qtd).All(&result)
-	} else {
-		err = c.Find(bson.M{}).Skip((page - 1) * qtd).Limit(qtd).All(&result)
+	err = m.find(c, bson.M{}, q).Skip(offset).Limit(limit).All(&result)
+	return result, err
+}
+
+func (m *MongoDialect) GetAllBySearch(collection string, searchtext string, field string, page int, qtd int, q Query) ([]JSONDoc, error) {
+	ss, err := m.copySession()
+	if err != nil {
+		return nil, err
 	}
+	defer ss.Close()
+	c := ss.DB(m.DBName).C(collection)
 
+	if page < 1 {
+		page = 1
+	}
+	var result []JSONDoc
+	err = m.find(c, bson.M{field: bson.RegEx{searchtext, ""}}, q).Skip((page - 1) * qtd).Limit(qtd).All(&result)
 	return result, err
 }
 
-func (m *MongoDialect) GetAllBySearch(collection string, searchtext string, field string, page int, qtd int, sorted string) ([]JSONDoc, error) {
-	ss := m.Session.Copy()
+func (m *MongoDialect) Update(collection string, json JSONDoc) error {
+	ss, err := m.copySession()
+	if err != nil {
+		return err
+	}
 	defer ss.Close()
 	c := ss.DB(m.DBName).C(collection)
+	if !m.Model.versioned(collection) {
+		return c.Update(bson.M{"_id": json["_id"]}, json)
+	}
 
-	var err error
-	var result []JSONDoc
-	if sorted != "" {
-		err = c.Find(bson.M{field: bson.RegEx{searchtext, ""}}).Sort(sorted).Skip((page - 1) * qtd).Limit(qtd).All(&result)
-	} else {
-		err = c.Find(bson.M{field: bson.RegEx{searchtext, ""}}).Skip((page - 1) * qtd).Limit(qtd).All(&result)
+	// conditional update, matching only the version the document was read with
+	version := cast.ToInt64(json[versionField])
+	selector := bson.M{"_id": json["_id"], versionField: version}
+	if version == 0 {
+		selector[versionField] = bson.M{"$in": []interface{}{0, nil}}
 	}
+	json[versionField] = version + 1
+	err = c.Update(selector, json)
+	if err != nil {
+		json[versionField] = version
+	}
+	if err == mgo.ErrNotFound {
+		if n, cerr := c.FindId(json["_id"]).Count(); cerr == nil && n > 0 {
+			return ErrStaleObject
+		}
+	}
+	return err
+}
 
-	return result, err
+//CreateMany  - validate the documents, then insert them with an ordered bulk
+//operation, on a write error the ones before the failing document stay inserted
+func (m *MongoDialect) CreateMany(collection string, docs []JSONDoc) error {
+	err := validateRows(collection, docs, m.Model, m.Config.Validations)
+	if err != nil {
+		return err
+	}
+	ss, err := m.copySession()
+	if err != nil {
+		return err
+	}
+	defer ss.Close()
+	c := ss.DB(m.DBName).C(collection)
+
+	bulk := c.Bulk()
+	for _, doc := range docs {
+		if doc["_id"] == nil {
+			doc["_id"] = bson.NewObjectId()
+		}
+		bulk.Insert(doc)
+	}
+	_, err = bulk.Run()
+	if berr, ok := err.(*mgo.BulkError); ok {
+		var errs BulkError
+		for _, bc := range berr.Cases() {
+			errs = append(errs, RowError{Index: bc.Index, Err: bc.Err})
+		}
+		return errs
+	}
+	return err
 }
 
-func (m *MongoDialect) Update(collection string, json JSONDoc) error {
-	ss := m.Session.Copy()
+//Upsert  - insert or update the document matching the keys with one upsert,
+//onInsert goes to $setOnInsert
+func (m *MongoDialect) Upsert(collection string, doc JSONDoc, keys []string, onInsert JSONDoc) (JSONDoc, error) {
+	ss, err := m.copySession()
+	if err != nil {
+		return nil, err
+	}
+	defer ss.Close()
+	c := ss.DB(m.DBName).C(collection)
+
+	selector := bson.M{}
+	for _, key := range keys {
+		selector[key] = doc[key]
+		if id, ok := doc[key].(string); ok && key == "_id" && bson.IsObjectIdHex(id) {
+			selector[key] = bson.ObjectIdHex(id)
+		}
+	}
+	set := bson.M{}
+	for k, v := range doc {
+		if k != "_id" {
+			set[k] = v
+		}
+	}
+	update := bson.M{"$set": set}
+	insertOnly := bson.M{}
+	for k, v := range onInsert {
+		if k != versionField {
+			insertOnly[k] = v
+		}
+	}
+	if len(insertOnly) > 0 {
+		update["$setOnInsert"] = insertOnly
+	}
+	if m.Model.versioned(collection) {
+		update["$inc"] = bson.M{versionField: 1}
+	}
+	_, err = c.Upsert(selector, update)
+	if err != nil {
+		return nil, err
+	}
+	var stored JSONDoc
+	err = c.Find(selector).One(&stored)
+	return stored, err
+}
+
+//Patch  - change some fields of the document with the $set, $inc, $push and
+//$unset operators of a single update
+func (m *MongoDialect) Patch(collection string, id string, ops []FieldOp) error {
+	err := validateChanged(collection, changedFields(ops), m.Model, m.Config.Validations)
+	if err != nil {
+		return err
+	}
+	ss, err := m.copySession()
+	if err != nil {
+		return err
+	}
 	defer ss.Close()
 	c := ss.DB(m.DBName).C(collection)
-	return c.Update(bson.M{"_id": json["_id"]}, json)
+	if !bson.IsObjectIdHex(id) {
+		return fmt.Errorf("Mongo ObjectID is invalid")
+	}
+
+	update := bson.M{}
+	for _, op := range ops {
+		fields, ok := update[op.op].(bson.M)
+		if !ok {
+			fields = bson.M{}
+			update[op.op] = fields
+		}
+		if op.op == "$unset" {
+			fields[op.field] = ""
+		} else {
+			fields[op.field] = op.value
+		}
+	}
+	if m.Model.versioned(collection) {
+		inc, ok := update["$inc"].(bson.M)
+		if !ok {
+			inc = bson.M{}
+			update["$inc"] = inc
+		}
+		inc[versionField] = 1
+	}
+	return c.UpdateId(bson.ObjectIdHex(id), update)
 }
 
 func (m *MongoDialect) Delete(collection string, id string) error {
-	ss := m.Session.Copy()
+	ss, err := m.copySession()
+	if err != nil {
+		return err
+	}
 	defer ss.Close()
 	c := ss.DB(m.DBName).C(collection)
 	return c.Remove(bson.M{"_id": bson.ObjectIdHex(id)})
 }
 
-func (m *MongoDialect) DeleteByWhere(collection string, query string) error {
-	ss := m.Session.Copy()
+func (m *MongoDialect) DeleteByWhere(collection string, q Query) error {
+	ss, err := m.copySession()
+	if err != nil {
+		return err
+	}
 	defer ss.Close()
 	c := ss.DB(m.DBName).C(collection)
-	q := make(map[string]interface{})
-	err := json.Unmarshal([]byte(query), &q)
+	filter, err := m.filter(q)
 	if err != nil {
 		return err
 	}
-	return c.Remove(q)
+	return c.Remove(filter)
+}
+
+//Aggregate  - the groups with a $match and $group pipeline, filtered by
+//Having, sorted and paged once flattened
+func (m *MongoDialect) Aggregate(collection string, q Query, agg Aggregation) ([]JSONDoc, error) {
+	ss, err := m.copySession()
+	if err != nil {
+		return nil, err
+	}
+	defer ss.Close()
+	c := ss.DB(m.DBName).C(collection)
+
+	filter, err := m.filter(q)
+	if err != nil {
+		return nil, err
+	}
+	var id interface{}
+	if len(agg.GroupBy) > 0 {
+		key := bson.M{}
+		for i, field := range agg.GroupBy {
+			key[fmt.Sprintf("g%d", i)] = "$" + field
+		}
+		id = key
+	}
+	group := bson.M{"_id": id}
+	for _, acc := range agg.Accumulators {
+		if acc.op == "$count" {
+			group[acc.alias] = bson.M{"$sum": 1}
+		} else {
+			group[acc.alias] = bson.M{acc.op: "$" + acc.field}
+		}
+	}
+	var pipeline []bson.M
+	if len(filter) > 0 {
+		pipeline = append(pipeline, bson.M{"$match": filter})
+	}
+	pipeline = append(pipeline, bson.M{"$group": group})
+
+	var list []JSONDoc
+	err = c.Pipe(pipeline).All(&list)
+	if err != nil {
+		return nil, err
+	}
+	for _, doc := range list {
+		values := make([]interface{}, len(agg.GroupBy))
+		for i := range agg.GroupBy {
+			values[i], _ = lookupField(doc, fmt.Sprintf("_id.g%d", i))
+		}
+		delete(doc, "_id")
+		for i, field := range agg.GroupBy {
+			doc[groupName(field)] = values[i]
+		}
+	}
+	return finishGroups(list, agg, q)
 }
 
 func (m *MongoDialect) GetByGroup(collection string, query map[string]interface{}) (JSONDoc, error) {
 	//db.empresa.aggregate( [ { $group: { _id: null, total: { $sum: "$InteresseEmprestimo" } } } ] )
-	ss := m.Session.Copy()
+	ss, err := m.copySession()
+	if err != nil {
+		return nil, err
+	}
 	defer ss.Close()
 	c := ss.DB(m.DBName).C(collection)
 
@@ -254,6 +553,58 @@ func (m *MongoDialect) GetByGroup(collection string, query map[string]interface{
 	//		}},
 	//	}
 	var result JSONDoc
-	err := c.Pipe(query).One(&result)
+	err = c.Pipe(query).One(&result)
 	return result, err
 }
+
+//SyncSchema  - create the unique and secondary indexes of the model fields
+//missing in their collections, documents have no schema to change
+func (m *MongoDialect) SyncSchema(mode string) ([]SchemaChange, error) {
+	ss, err := m.copySession()
+	if err != nil {
+		return nil, err
+	}
+	defer ss.Close()
+
+	var changes []SchemaChange
+	for _, name := range m.Model.tableNames() {
+		c := ss.DB(m.DBName).C(name)
+		indexes, err := c.Indexes()
+		if qerr, ok := err.(*mgo.QueryError); ok && qerr.Code == 26 {
+			// NamespaceNotFound, the collection does not exist yet
+			indexes, err = nil, nil
+		}
+		if err != nil {
+			return changes, err
+		}
+		for _, f := range m.Model.Tables[name].Fields {
+			if !f.Unique && !f.Index || hasMongoIndex(indexes, f.column(), f.Unique) {
+				continue
+			}
+			index := mgo.Index{Key: []string{f.column()}, Unique: f.Unique, Name: "ix_" + f.column()}
+			if f.Unique {
+				index.Name = "ux_" + f.column()
+			}
+			change := SchemaChange{Table: name, Column: f.column(), Action: "create index", Detail: index.Name}
+			if change.appliesIn(mode) {
+				err = c.EnsureIndex(index)
+				if err != nil {
+					return changes, err
+				}
+				change.Applied = true
+			}
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+// hasMongoIndex - true when an index on the single field exists, unique when asked
+func hasMongoIndex(indexes []mgo.Index, field string, unique bool) bool {
+	for _, index := range indexes {
+		if len(index.Key) == 1 && index.Key[0] == field && (index.Unique || !unique) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,349 @@
+package gorgo
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/spf13/cast"
+)
+
+func newMockMySQL(t *testing.T) (*ORM, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &model{Tables: map[string]table{
+		"user": {Name: "user", Fields: []*field{{Name: "_id", Type: "bigint", Autoincrement: true}}},
+	}}
+	dialect := &MySQLDialect{}
+	dialect.DB = db
+	dialect.Model = m
+	dialect.Config = ConfigDB{Validations: GetFunctions()}
+	dialect.syntax()
+	return &ORM{dialectDB: dialect}, mock
+}
+
+func TestMySQLDialect_Create(t *testing.T) {
+	DB, mock := newMockMySQL(t)
+	defer DB.Close()
+
+	mock.ExpectExec("INSERT INTO `user` (`age`,`email`,`name`) VALUES (?,?,?)").
+		WithArgs(25, "jd@test.com", "john Doe").
+		WillReturnResult(sqlmock.NewResult(7, 1))
+
+	user := JSONDoc{"name": "john Doe", "email": "jd@test.com", "age": 25}
+	userRet, err := DB.Table("user").Insert(user)
+	if err != nil {
+		t.Fatal("DB Create Error : ", err)
+	}
+	if userRet["_id"] != int64(7) {
+		t.Fatalf("expected _id 7, got %v", userRet["_id"])
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMySQLDialect_GetManyByQuery(t *testing.T) {
+	DB, mock := newMockMySQL(t)
+	defer DB.Close()
+
+	rows := sqlmock.NewRowsWithColumnDefinition(
+		sqlmock.NewColumn("_id").OfType("BIGINT", int64(0)),
+		sqlmock.NewColumn("name").OfType("VARCHAR", ""),
+		sqlmock.NewColumn("score").OfType("DECIMAL", ""),
+		sqlmock.NewColumn("created").OfType("DATETIME", time.Time{}),
+		sqlmock.NewColumn("email").OfType("VARCHAR", ""),
+	).AddRow([]byte("1"), []byte("john Doe"), []byte("9.5"), []byte("2018-01-02 10:11:12"), nil)
+	mock.ExpectQuery("SELECT * FROM `user` WHERE age > ?").WithArgs(18).WillReturnRows(rows)
+
+	list, err := DB.Table("user").Where("age > ?", 18).Get()
+	if err != nil {
+		t.Fatal("DB GetManyByQuery Error : ", err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(list))
+	}
+	doc := list[0]
+	if doc["_id"] != int64(1) || doc["name"] != "john Doe" || doc["score"] != 9.5 || doc["email"] != nil {
+		t.Fatalf("unexpected column typing: %#v", doc)
+	}
+	if _, ok := doc["created"].(time.Time); !ok {
+		t.Fatalf("expected created as time.Time, got %T", doc["created"])
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMySQLDialect_GetAll(t *testing.T) {
+	DB, mock := newMockMySQL(t)
+	defer DB.Close()
+
+	rows := sqlmock.NewRows([]string{"_id", "name"}).AddRow(int64(2), "jane")
+	mock.ExpectQuery("SELECT * FROM `user` ORDER BY `name` DESC LIMIT 5 OFFSET 10").WillReturnRows(rows)
+
+	list, err := DB.dialectDB.GetAll("user", 10, 5, Query{Order: "-name"})
+	if err != nil {
+		t.Fatal("DB GetAll Error : ", err)
+	}
+	if len(list) != 1 || list[0]["name"] != "jane" {
+		t.Fatalf("unexpected result %v", list)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMySQLDialect_UpdateDelete(t *testing.T) {
+	DB, mock := newMockMySQL(t)
+	defer DB.Close()
+
+	mock.ExpectExec("UPDATE `user` SET `email` = ?, `name` = ? WHERE `_id` = ?").
+		WithArgs("jd@test.com", "upd john doe", int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM `user` WHERE `_id` = ?").
+		WithArgs("7").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT COUNT(*) FROM `user` WHERE age > 18").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	err := DB.Table("user").Update(JSONDoc{"_id": int64(7), "name": "upd john doe", "email": "jd@test.com"})
+	if err != nil {
+		t.Fatal("DB Update Error : ", err)
+	}
+	err = DB.Table("user").DeleteByID("7")
+	if err != nil {
+		t.Fatal("DB Delete Error : ", err)
+	}
+	i, err := DB.dialectDB.CountByWhere("user", Query{Where: "age > 18"})
+	if err != nil || i != 3 {
+		t.Fatal("DB CountByWhere Error : ", i, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMySQLDialect_GetByGroup(t *testing.T) {
+	DB, mock := newMockMySQL(t)
+	defer DB.Close()
+
+	mock.ExpectQuery("SELECT `city` AS `_id`, COUNT(*)*1 AS `count`, SUM(`amount`) AS `total` FROM `user` GROUP BY `city` LIMIT 1").
+		WillReturnRows(sqlmock.NewRows([]string{"_id", "count", "total"}).AddRow("rio", 2, 30.5))
+
+	doc, err := DB.dialectDB.GetByGroup("user", map[string]interface{}{
+		"$group": map[string]interface{}{
+			"_id":   "$city",
+			"total": map[string]interface{}{"$sum": "$amount"},
+			"count": map[string]interface{}{"$sum": 1},
+		},
+	})
+	if err != nil {
+		t.Fatal("DB GetByGroup Error : ", err)
+	}
+	if doc["_id"] != "rio" || doc["total"] != 30.5 {
+		t.Fatalf("unexpected result %v", doc)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMySQLDialect_InsertStruct(t *testing.T) {
+	DB, mock := newMockMySQL(t)
+	defer DB.Close()
+
+	type user struct {
+		ID      int64     `gorgo:"_id"`
+		Name    string    `gorgo:"name"`
+		Created time.Time `gorgo:"created"`
+	}
+	created := time.Date(2018, 1, 2, 10, 11, 12, 0, time.UTC)
+
+	mock.ExpectExec("INSERT INTO `user` (`created`,`name`) VALUES (?,?)").
+		WithArgs(created, "john Doe").
+		WillReturnResult(sqlmock.NewResult(9, 1))
+
+	u := &user{Name: "john Doe", Created: created}
+	err := DB.Table("user").InsertStruct(u)
+	if err != nil {
+		t.Fatal("DB InsertStruct Error : ", err)
+	}
+	if u.ID != 9 {
+		t.Fatalf("expected id 9 written back, got %d", u.ID)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMySQLDialect_Transaction(t *testing.T) {
+	DB, mock := newMockMySQL(t)
+	defer DB.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `user` (`name`) VALUES (?)").
+		WithArgs("john Doe").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("DELETE FROM `user` WHERE `_id` = ?").
+		WithArgs("1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := DB.Transaction(func(tx *Session) error {
+		user, err := tx.Table("user").Insert(JSONDoc{"name": "john Doe"})
+		if err != nil {
+			return err
+		}
+		return tx.Table("user").DeleteByID(cast.ToString(user["_id"]))
+	})
+	if err != nil {
+		t.Fatal("DB Transaction Error : ", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `user` (`name`) VALUES (?)").
+		WithArgs("jane Doe").
+		WillReturnError(fmt.Errorf("duplicate entry"))
+	mock.ExpectRollback()
+
+	err = DB.Transaction(func(tx *Session) error {
+		_, err := tx.Table("user").Insert(JSONDoc{"name": "jane Doe"})
+		return err
+	})
+	if err == nil || err.Error() != "duplicate entry" {
+		t.Fatal("expected the insert error, got ", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMySQLDialect_Versioned(t *testing.T) {
+	DB, mock := newMockMySQL(t)
+	defer DB.Close()
+	DB.dialectDB.(*MySQLDialect).Model.Tables["account"] = table{
+		Name:      "account",
+		Fields:    []*field{{Name: "_id", Type: "bigint", Autoincrement: true}, {Name: "_version", Type: "bigint"}},
+		Versioned: true,
+	}
+
+	mock.ExpectExec("UPDATE `account` SET `_version` = ?, `name` = ? WHERE (`_id` = ? AND `_version` = ?)").
+		WithArgs(3, "john", 9, 2).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT COUNT(*) FROM `account` WHERE `_id` = ?").WithArgs(9).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	doc := JSONDoc{"_id": 9, "name": "john", "_version": 2}
+	err := DB.Table("account").Update(doc)
+	if err != ErrStaleObject {
+		t.Fatal("expected ErrStaleObject, got ", err)
+	}
+	if doc["_version"] != int64(2) {
+		t.Fatal("version not restored after a stale update: ", doc["_version"])
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMySQLDialect_Patch(t *testing.T) {
+	DB, mock := newMockMySQL(t)
+	defer DB.Close()
+	DB.dialectDB.(*MySQLDialect).Model.Tables["product"] = table{
+		Name:   "product",
+		Fields: []*field{{Name: "_id", Type: "bigint", Autoincrement: true}, {Name: "stock", Type: "int"}, {Name: "promo", Type: "varchar"}},
+	}
+
+	mock.ExpectExec("UPDATE `product` SET `stock` = COALESCE(`stock`, 0) + ?, `promo` = ? WHERE `_id` = ?").
+		WithArgs(-1, nil, "7").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := DB.Table("product").Patch("7", nil, Inc("stock", -1), Unset("promo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMySQLDialect_InsertManyUpsert(t *testing.T) {
+	DB, mock := newMockMySQL(t)
+	defer DB.Close()
+	DB.dialectDB.(*MySQLDialect).Model.Tables["product"] = table{
+		Name:   "product",
+		Fields: []*field{{Name: "_id", Type: "bigint", Autoincrement: true}, {Name: "sku", Type: "varchar", Unique: true}, {Name: "stock", Type: "int"}},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `product` (`sku`,`stock`) VALUES (?,?),(?,?)").
+		WithArgs("a1", 5, "b2", 7).
+		WillReturnResult(sqlmock.NewResult(10, 2))
+	mock.ExpectCommit()
+	docs := []JSONDoc{{"sku": "a1", "stock": 5}, {"sku": "b2", "stock": 7}}
+	err := DB.Table("product").InsertMany(docs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if docs[0]["_id"] != int64(10) || docs[1]["_id"] != int64(11) {
+		t.Fatal("ids of a multi-row insert: ", docs)
+	}
+
+	mock.ExpectExec("INSERT INTO `product` (`sku`,`stock`) VALUES (?,?) ON DUPLICATE KEY UPDATE `sku` = VALUES(`sku`), `stock` = VALUES(`stock`)").
+		WithArgs("a1", 9).
+		WillReturnResult(sqlmock.NewResult(10, 2))
+	mock.ExpectQuery("SELECT * FROM `product` WHERE (`sku` = ?) LIMIT 1").WithArgs("a1").
+		WillReturnRows(sqlmock.NewRows([]string{"_id", "sku", "stock"}).AddRow(10, "a1", 9))
+	stored, err := DB.Table("product").Upsert(JSONDoc{"sku": "a1", "stock": 9}, "sku")
+	if err != nil || cast.ToInt(stored["_id"]) != 10 {
+		t.Fatal("Upsert Error : ", stored, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMySQLDialect_UpsertVersioned(t *testing.T) {
+	DB, mock := newMockMySQL(t)
+	defer DB.Close()
+	DB.dialectDB.(*MySQLDialect).Model.Tables["product"] = table{
+		Name:      "product",
+		Fields:    []*field{{Name: "_id", Type: "bigint", Autoincrement: true}, {Name: "sku", Type: "varchar", Unique: true}, {Name: "stock", Type: "int"}},
+		Versioned: true,
+	}
+
+	mock.ExpectExec("INSERT INTO `product` (`_version`,`sku`,`stock`) VALUES (?,?,?) ON DUPLICATE KEY UPDATE `sku` = VALUES(`sku`), `stock` = VALUES(`stock`), `_version` = COALESCE(`_version`, 0) + 1").
+		WithArgs(1, "a1", 9).
+		WillReturnResult(sqlmock.NewResult(10, 2))
+	mock.ExpectQuery("SELECT * FROM `product` WHERE (`sku` = ?) LIMIT 1").WithArgs("a1").
+		WillReturnRows(sqlmock.NewRows([]string{"_id", "sku", "stock", "_version"}).AddRow(10, "a1", 9, 2))
+	stored, err := DB.Table("product").Upsert(JSONDoc{"sku": "a1", "stock": 9}, "sku")
+	if err != nil || cast.ToInt(stored["_version"]) != 2 {
+		t.Fatal("Upsert Error : ", stored, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMySQLDialect_GroupBy(t *testing.T) {
+	DB, mock := newMockMySQL(t)
+	defer DB.Close()
+
+	mock.ExpectQuery("SELECT * FROM (SELECT `city` AS `city`, SUM(`amount`) AS `total`, COUNT(*) AS `count` FROM `sale` WHERE `year` = ? GROUP BY `city`) AS g WHERE `count` > ? ORDER BY `total` DESC LIMIT 5").
+		WithArgs(2024, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"city", "total", "count"}).AddRow("Rio", 1520.5, 34))
+
+	groups, err := DB.Table("sale").Where(Eq("year", 2024)).GroupBy("city").Sum("amount", "total").Count().
+		Having(Gt("count", 10)).OrderBy("-total").Limit(5).Get()
+	if err != nil || len(groups) != 1 || groups[0]["city"] != "Rio" {
+		t.Fatal("GroupBy Error : ", groups, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,10 +1,8 @@
 package gorgo
 
 import (
-	"bytes"
-	"database/sql"
-	"log"
-	"sync"
+	"context"
+	"strings"
 
 	sq "github.com/Masterminds/squirrel"
 	_ "github.com/go-sql-driver/mysql" // Package for mysql driver
@@ -13,223 +11,91 @@ import (
 
 //MySQLDialect - dialect for mysql database
 type MySQLDialect struct {
-	DB          *sql.DB
-	ShowSQL     bool
-	NamedQuerys map[string]string
-	CachedMutex sync.Mutex
+	sqlDialect
+}
+
+func init() {
+	RegisterDialect("mysql", func() Dialect { return &MySQLDialect{} })
+}
+
+// syntax - set the mysql flavor of the shared sql dialect
+func (m *MySQLDialect) syntax() {
+	m.quote = quoteMySQL
+	m.placeholder = sq.Question
+	m.like = "LIKE"
+	m.serialType = "BIGINT AUTO_INCREMENT"
+	m.columnType = mysqlType
+	m.columnsQuery = "SELECT column_name, column_type FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ?"
+	m.indexesQuery = "SELECT DISTINCT index_name FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = ?"
+	m.alterType = func(table string, column string, columnType string) string {
+		return "ALTER TABLE " + table + " MODIFY COLUMN " + column + " " + columnType
+	}
+	m.upsert = func(table string, keys []string, columns []string, counters []string) string {
+		var sets []string
+		for _, col := range columns {
+			sets = append(sets, col+" = VALUES("+col+")")
+		}
+		for _, col := range counters {
+			sets = append(sets, col+" = COALESCE("+col+", 0) + 1")
+		}
+		return "ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+	}
+	m.firstInsertID = true
 }
 
 //InitDB  - initialize database
 func (m *MySQLDialect) InitDB(config ConfigDB) error {
+	m.syntax()
+
 	user := config.User
 	server := config.Server
 	pass := config.Password
 	port := config.Port
 	database := config.Database
-	maxIdle := config.MaxIdle
-	maxOpen := config.MaxOpen
 	sport := cast.ToString(port)
 	// "root:@tcp(localhost:3306)/certra"
 	var url = user + ":" + pass + "@tcp(" + server + ":" + sport + ")/" + database + "?parseTime=true&timeout=30s"
-	//var url = database + "/" + user + "/" + pass
-	db, err := sql.Open("mysql", url)
-	if err != nil {
-		return err
-	}
-
-	db.SetMaxIdleConns(int(maxIdle))
-	db.SetMaxOpenConns(int(maxOpen))
-	m.DB = db
-	m.ShowSQL = config.ShowSQL
-	m.NamedQuerys = make(map[string]string)
-
-	return nil
-}
-
-//CloseDB  - close database
-func (m *MySQLDialect) CloseDB() error {
-	return m.DB.Close()
-
-}
-
-func (m *MySQLDialect) Create(tableName string, data JSONDoc) (int64, error) {
-
-	m.CachedMutex.Lock()
-
-	keys := sortMap(data)
-
-	var sql = m.NamedQuerys["insert-"+tableName]
-
-	if len(sql) == 0 {
-		query := sq.Insert(tableName).Columns(keys...)
-		for _, k := range keys {
-			v := data[k]
-			if k != "id" {
-				query.Values(v)
-			}
-		}
-		var err error
-		sql, _, err = query.ToSql()
-		if err != nil {
-			return 0, err
-		}
-		m.NamedQuerys["insert-"+tableName] = sql
-	}
-	m.CachedMutex.Unlock()
-
-	if m.ShowSQL == true {
-		log.Println("SQL=", sql)
+	if config.UseSSL {
+		url += "&tls=true"
 	}
-
-	stmt, err := m.DB.Prepare(sql)
-	if err != nil {
-		return 0, err
-	}
-
-	var params []interface{}
-
-	for _, k := range keys {
-		v := data[k]
-		if k != "id" {
-			params = append(params, v)
-		}
-	}
-
-	res, err := stmt.Exec(params...)
-	if err != nil {
-		return 0, err
-	}
-	lastId, err := res.LastInsertId()
-	if err != nil {
-		return 0, err
-	}
-	stmt.Close()
-	return lastId, nil
+	return m.open("mysql", url, config)
 }
 
-func (s *MySQLDialect) CreateInterface(collection string, i interface{}) error {
-	return nil
+//WithContext  - copy of the dialect running its statements with ctx
+func (m *MySQLDialect) WithContext(ctx context.Context) Dialect {
+	dst := &MySQLDialect{}
+	m.withContext(&dst.sqlDialect, ctx)
+	return dst
 }
 
-func (m *MySQLDialect) Update(tableName string, data JSONDoc) (int64, error) {
-
-	m.CachedMutex.Lock()
-	defer m.CachedMutex.Unlock()
-
-	keys := sortMap(data)
-
-	var sql = m.NamedQuerys["update-"+tableName]
-	if len(sql) == 0 {
-		builder := sq.Update(tableName)
-		for _, k := range keys {
-			if k != "id" {
-				builder.Set(k, data[k])
-			}
-		}
-		builder.Where("id = ?", 1)
-		var err error
-		sql, _, err = builder.ToSql()
-		if err != nil {
-			return 0, err
-		}
-		m.NamedQuerys["update-"+tableName] = sql
-	}
-
-	if m.ShowSQL == true {
-		log.Println("SQL=", sql)
-	}
-
-	stmt, err := m.DB.Prepare(sql)
-	if err != nil {
-		return 0, err
-	}
-
-	var params []interface{}
-
-	var id interface{}
-
-	for _, k := range keys {
-		v := data[k]
-		if k != "id" {
-			params = append(params, v)
-		} else {
-			id = v
-		}
-	}
-
-	params = append(params, id)
-
-	res, err := stmt.Exec(params...)
-	if err != nil {
-		return 0, err
-	}
-	rowCnt, err := res.RowsAffected()
+//Begin  - open a transaction, the returned dialect runs every call inside it
+func (m *MySQLDialect) Begin() (TxDialect, error) {
+	txd := &MySQLDialect{}
+	err := m.begin(&txd.sqlDialect)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	stmt.Close()
-	return rowCnt, nil
+	return txd, nil
 }
 
-func (m *MySQLDialect) Delete(tableName string, id int64) (int64, error) {
-	m.CachedMutex.Lock()
-	defer m.CachedMutex.Unlock()
-
-	var sql = m.NamedQuerys["deletebyid-"+tableName]
-	if len(sql) == 0 {
-		var err error
-		sql, _, err = sq.Delete(tableName).Where("id = ?", id).ToSql()
-		if err != nil {
-			return 0, err
-		}
-		m.NamedQuerys["deletebyid-"+tableName] = sql
-	}
-
-	if m.ShowSQL == true {
-		log.Println("SQL=", sql)
-	}
-	stmt, err := m.DB.Prepare(sql)
-	defer stmt.Close()
-	if err != nil {
-		return 0, err
-	}
-	// log.Println("delete id=", id)
-	res, err := stmt.Exec(id)
-	if err != nil {
-		return 0, err
-	}
-	i, _ := res.RowsAffected()
-	return i, nil
+func quoteMySQL(name string) string {
+	return "`" + strings.Replace(name, "`", "``", -1) + "`"
 }
 
-func (m *MySQLDialect) Count(tableName string) (int64, error) {
-	m.CachedMutex.Lock()
-	defer m.CachedMutex.Unlock()
-
-	var sqlbuffer bytes.Buffer
-	//hash := calcHash("count-" + tableName + orm.WhereStr)
-	sqlbuffer.WriteString(m.NamedQuerys["count-"+tableName])
-
-	if sqlbuffer.Len() == 0 {
-
-		sqlbuffer.WriteString("SELECT COUNT(*) FROM ")
-		sqlbuffer.WriteString(tableName)
-
-	}
-	m.NamedQuerys["count-"+tableName] = sqlbuffer.String()
-
-	if m.ShowSQL == true {
-		log.Println("SQL=", sqlbuffer.String())
-	}
-
-	stmt, err := m.DB.Prepare(sqlbuffer.String())
-	if err != nil {
-		return 0, err
-	}
-	row := stmt.QueryRow()
-	var i int64
-	row.Scan(&i)
-	//log.Println("interface=", ii)
-	stmt.Close()
-	return i, nil
+// mysqlType - column type for a model field type
+func mysqlType(fieldType string) string {
+	switch fieldType {
+	case "int":
+		return "int"
+	case "bigint":
+		return "bigint"
+	case "float":
+		return "float"
+	case "double":
+		return "double"
+	case "date":
+		return "datetime"
+	}
+	// varchar rather than text, a text column can not have a unique index
+	return "varchar(255)"
 }
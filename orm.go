@@ -1,44 +1,93 @@
 package gorgo
 
 import (
+	"context"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 )
 
 type ORM struct {
 	dialectDB Dialect
 	showSQL   bool
+	hooks     *hookSet
+	// migrations - registered by AddMigration, shared by the ORM copies
+	migrations *migrationSet
+	// inTx - the dialect is bound to a transaction
+	inTx bool
+	// actor - user of the context given to WithContext, see WithActor
+	actor interface{}
 }
 
 type FuncMap map[string]interface{}
 
+var (
+	dialectsMu sync.RWMutex
+	dialects   = make(map[string]func() Dialect)
+)
+
+// RegisterDialect - make a dialect available to NewOrm as ConfigDB.Type name.
+// Like database/sql.Register it panics when called twice for the same name.
+func RegisterDialect(name string, factory func() Dialect) {
+	dialectsMu.Lock()
+	defer dialectsMu.Unlock()
+	if factory == nil {
+		panic("gorgo: RegisterDialect factory is nil")
+	}
+	if _, dup := dialects[name]; dup {
+		panic("gorgo: RegisterDialect called twice for dialect " + name)
+	}
+	dialects[name] = factory
+}
+
+// Dialects - sorted names of the registered dialects
+func Dialects() []string {
+	dialectsMu.RLock()
+	defer dialectsMu.RUnlock()
+	var names []string
+	for name := range dialects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func NewOrm(config ConfigDB) (*ORM, error) {
 	dbtype := config.Type
 	if dbtype == "" {
 		return nil, fmt.Errorf("DB_TYPE not defined.")
 	}
 
-	config.Validations = GetFunctions()
+	dialectsMu.RLock()
+	factory, ok := dialects[dbtype]
+	dialectsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("Unknown dialect %q, known dialects: %s", dbtype, strings.Join(Dialects(), ", "))
+	}
 
-	switch dbtype {
-	case "mongo":
-		dialect := &MongoDialect{}
-		err := dialect.InitDB(config)
+	config.Validations = GetFunctions()
+	if config.SyncDB != "" {
+		err := validSyncMode(config.SyncDB)
 		if err != nil {
 			return nil, err
 		}
-		return &ORM{dialectDB: dialect}, nil
-	case "localdb":
-		dialect := &LocalDialect{}
-		err := dialect.InitDB(config)
+	}
+
+	dialect := factory()
+	err := dialect.InitDB(config)
+	if err != nil {
+		return nil, err
+	}
+	orm := &ORM{dialectDB: dialect, hooks: &hookSet{}, migrations: &migrationSet{}}
+	if config.SyncDB != "" {
+		err = orm.syncDB(config.SyncDB)
 		if err != nil {
+			dialect.CloseDB()
 			return nil, err
 		}
-		return &ORM{dialectDB: dialect}, nil
-	default:
-		return nil, fmt.Errorf("[WARNING] dbtype not found", nil)
 	}
-
-	return nil, nil
+	return orm, nil
 }
 
 func (d *ORM) NewSession() *Session {
@@ -65,13 +114,23 @@ func (d *ORM) Offset(i int) *Session {
 	return session
 }
 
-func (d *ORM) Where(query string, params ...interface{}) *Session {
+func (d *ORM) OrderBy(order string) *Session {
 	session := d.NewSession()
-	session.where = query
-	session.params = params
+	session.order = order
 	return session
 }
 
+func (d *ORM) Select(columns ...string) *Session {
+	session := d.NewSession()
+	session.columns = columns
+	return session
+}
+
+func (d *ORM) Where(query interface{}, params ...interface{}) *Session {
+	session := d.NewSession()
+	return session.Where(query, params...)
+}
+
 // Get Query a raw sql and return records as []map[string][]byte
 func (d *ORM) Get() ([]JSONDoc, error) {
 	session := d.NewSession()
@@ -114,6 +173,83 @@ func (d *ORM) Count() (int, error) {
 	return session.Count()
 }
 
+// WithContext - ORM whose calls run with ctx, dialects that are not a
+// ContextDialect ignore it
+func (d *ORM) WithContext(ctx context.Context) *ORM {
+	orm := *d
+	if cd, ok := d.dialectDB.(ContextDialect); ok {
+		orm.dialectDB = cd.WithContext(ctx)
+	}
+	if actor := ctx.Value(actorKey{}); actor != nil {
+		orm.actor = actor
+	}
+	return &orm
+}
+
+// Transaction - run fn in a transaction, committed when fn returns nil and
+// rolled back when it returns an error or panics. Every call made through tx,
+// or a session of tx.Table, runs inside the transaction. Returns
+// ErrTxNotSupported when the dialect has no transactions.
+func (d *ORM) Transaction(fn func(tx *Session) error) error {
+	return d.transaction(func(txOrm *ORM) error {
+		return fn(txOrm.NewSession())
+	})
+}
+
+func (d *ORM) transaction(fn func(txOrm *ORM) error) (err error) {
+	beginner, ok := d.dialectDB.(TxBeginner)
+	if !ok {
+		return ErrTxNotSupported
+	}
+	txd, err := beginner.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			txd.Rollback()
+			panic(p)
+		}
+	}()
+
+	txOrm := *d
+	txOrm.dialectDB = txd
+	txOrm.inTx = true
+	err = fn(&txOrm)
+	if err != nil {
+		if rerr := txd.Rollback(); rerr != nil {
+			return fmt.Errorf("%v, rollback error: %v", err, rerr)
+		}
+		return err
+	}
+	return txd.Commit()
+}
+
+// primaryKey - id field of the records of the table
+func (d *ORM) primaryKey(tableName string) string {
+	if kd, ok := d.dialectDB.(keyedDialect); ok {
+		return kd.pk(tableName)
+	}
+	return "_id"
+}
+
+// atomic - run fn in a transaction, or in the current one, falling back to
+// running it without when the dialect has no transactions
+func (d *ORM) atomic(fn func(txOrm *ORM) error) error {
+	if d.inTx {
+		return fn(d)
+	}
+	called := false
+	err := d.transaction(func(txOrm *ORM) error {
+		called = true
+		return fn(txOrm)
+	})
+	if err == ErrTxNotSupported && !called {
+		return fn(d)
+	}
+	return err
+}
+
 func (d *ORM) Close() error {
 	return d.dialectDB.CloseDB()
 }
@@ -0,0 +1,591 @@
+package gorgo
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cast"
+)
+
+type fakeDialect struct {
+	MySQLDialect
+	config ConfigDB
+}
+
+func (f *fakeDialect) InitDB(config ConfigDB) error {
+	f.config = config
+	return nil
+}
+
+func TestRegisterDialect(t *testing.T) {
+	var created *fakeDialect
+	RegisterDialect("fake", func() Dialect {
+		created = &fakeDialect{}
+		return created
+	})
+
+	DB, err := NewOrm(ConfigDB{Type: "fake", Server: "kv://local"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if DB.dialectDB != created || created.config.Server != "kv://local" {
+		t.Fatal("registered dialect was not initialized")
+	}
+
+	_, err = NewOrm(ConfigDB{Type: "nosuchdb"})
+	if err == nil {
+		t.Fatal("expected error for unknown dialect")
+	}
+	for _, name := range []string{"fake", "localdb", "mongo", "mysql", "postgres", "sqlite"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Fatalf("error %q does not list dialect %s", err, name)
+		}
+	}
+}
+
+func TestORM_Transaction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	modelFile := filepath.Join(dir, "model.json")
+	err = ioutil.WriteFile(modelFile, []byte(sqliteTestModel), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dialect := range []string{"memory", "localdb", "sqlite"} {
+		config := ConfigDB{}
+		config.ModelFile = modelFile
+		config.Type = dialect
+		config.SyncDB = SyncCreate
+		config.Server = filepath.Join(dir, dialect+".db")
+		DB, err := NewOrm(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = DB.Transaction(func(tx *Session) error {
+			for _, name := range []string{"john Doe", "jane Doe"} {
+				_, err := tx.Table("user").Insert(JSONDoc{"name": name, "email": name[:4] + "@test.com"})
+				if err != nil {
+					return err
+				}
+			}
+			i, err := tx.Table("user").Count()
+			if err != nil || i != 2 {
+				return fmt.Errorf("expected 2 users inside the transaction, got %d %v", i, err)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(dialect, " Transaction Error : ", err)
+		}
+
+		// the unique violation rolls back the first insert
+		err = DB.Transaction(func(tx *Session) error {
+			_, err := tx.Table("user").Insert(JSONDoc{"name": "mary Doe", "email": "mary@test.com"})
+			if err != nil {
+				return err
+			}
+			_, err = tx.Table("user").Insert(JSONDoc{"name": "other", "email": "john@test.com"})
+			return err
+		})
+		if err == nil {
+			t.Fatal(dialect, " expected unique key violation")
+		}
+		err = DB.Transaction(func(tx *Session) error {
+			return tx.orm.Transaction(func(*Session) error { return nil })
+		})
+		if err == nil {
+			t.Fatal(dialect, " expected nested transaction error")
+		}
+
+		i, err := DB.Table("user").Count()
+		if err != nil || i != 2 {
+			t.Fatal(dialect, " Count Error : ", i, err)
+		}
+		DB.Close()
+	}
+}
+
+func TestSession_WithContext(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	modelFile := filepath.Join(dir, "model.json")
+	err = ioutil.WriteFile(modelFile, []byte(sqliteTestModel), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dialect := range []string{"localdb", "sqlite"} {
+		config := ConfigDB{}
+		config.ModelFile = modelFile
+		config.Type = dialect
+		config.SyncDB = SyncCreate
+		config.Server = filepath.Join(dir, dialect+".db")
+		DB, err := NewOrm(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		_, err = DB.Table("user").WithContext(ctx).Insert(JSONDoc{"name": "john Doe", "email": "john@test.com"})
+		if err != nil {
+			t.Fatal(dialect, " DB Create Error : ", err)
+		}
+		cancel()
+
+		_, err = DB.Table("user").WithContext(ctx).Get()
+		if err != context.Canceled {
+			t.Fatal(dialect, " expected context.Canceled, got ", err)
+		}
+		err = DB.WithContext(ctx).Transaction(func(tx *Session) error { return nil })
+		if err == nil {
+			t.Fatal(dialect, " expected transaction to fail with a cancelled context")
+		}
+		i, err := DB.Table("user").Count()
+		if err != nil || i != 1 {
+			t.Fatal(dialect, " Count Error : ", i, err)
+		}
+		DB.Close()
+	}
+}
+
+func TestSession_SoftDelete(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	modelFile := filepath.Join(dir, "model.json")
+	model := strings.Replace(sqliteTestModel, `"name": "user",`, `"name": "user", "softDelete": true,`, 1)
+	err = ioutil.WriteFile(modelFile, []byte(model), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dialect := range []string{"memory", "localdb", "sqlite"} {
+		config := ConfigDB{}
+		config.ModelFile = modelFile
+		config.Type = dialect
+		config.SyncDB = SyncCreate
+		config.Server = filepath.Join(dir, dialect+".db")
+		DB, err := NewOrm(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var ids []string
+		for i, name := range []string{"john Doe", "jane Doe", "mary Ann"} {
+			doc, err := DB.Table("user").Insert(JSONDoc{"name": name, "email": name[:4] + "@test.com", "age": 20 + i*10})
+			if err != nil {
+				t.Fatal(dialect, " DB Create Error : ", err)
+			}
+			ids = append(ids, fmt.Sprint(doc[DB.primaryKey("user")]))
+		}
+
+		err = DB.Table("user").DeleteByID(ids[0])
+		if err != nil {
+			t.Fatal(dialect, " DeleteByID Error : ", err)
+		}
+		err = DB.Table("user").Where(Gt("age", 35)).DeleteByWhere()
+		if err != nil {
+			t.Fatal(dialect, " DeleteByWhere Error : ", err)
+		}
+		list, err := DB.Table("user").Get()
+		if err != nil || len(list) != 1 || list[0]["name"] != "jane Doe" {
+			t.Fatal(dialect, " soft deleted records returned : ", list, err)
+		}
+		i, err := DB.Table("user").Where(Like("name", "%Doe")).Count()
+		if err != nil || i != 1 {
+			t.Fatal(dialect, " Count Error : ", i, err)
+		}
+		_, err = DB.Table("user").GetByID(ids[0])
+		if err != ErrNotFound {
+			t.Fatal(dialect, " expected ErrNotFound, got ", err)
+		}
+		doc, err := DB.Table("user").WithDeleted().GetByID(ids[0])
+		if err != nil || doc["_deleted"] == nil {
+			t.Fatal(dialect, " WithDeleted Error : ", doc, err)
+		}
+		i, err = DB.Table("user").WithDeleted().Count()
+		if err != nil || i != 3 {
+			t.Fatal(dialect, " WithDeleted Count Error : ", i, err)
+		}
+
+		err = DB.Table("user").Restore(ids[0])
+		if err != nil {
+			t.Fatal(dialect, " Restore Error : ", err)
+		}
+		err = DB.Table("user").Purge(ids[2])
+		if err != nil {
+			t.Fatal(dialect, " Purge Error : ", err)
+		}
+		i, err = DB.Table("user").Count()
+		if err != nil || i != 2 {
+			t.Fatal(dialect, " Count Error : ", i, err)
+		}
+		i, err = DB.Table("user").WithDeleted().Count()
+		if err != nil || i != 2 {
+			t.Fatal(dialect, " Purge Error : ", i, err)
+		}
+		DB.Close()
+	}
+}
+
+func TestSession_Versioned(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	modelFile := filepath.Join(dir, "model.json")
+	model := strings.Replace(sqliteTestModel, `"name": "user",`, `"name": "user", "versioned": true,`, 1)
+	err = ioutil.WriteFile(modelFile, []byte(model), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dialect := range []string{"memory", "localdb", "sqlite"} {
+		config := ConfigDB{}
+		config.ModelFile = modelFile
+		config.Type = dialect
+		config.SyncDB = SyncCreate
+		config.Server = filepath.Join(dir, dialect+".db")
+		DB, err := NewOrm(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		user, err := DB.Table("user").Insert(JSONDoc{"name": "john Doe", "email": "john@test.com"})
+		if err != nil {
+			t.Fatal(dialect, " DB Create Error : ", err)
+		}
+		id := fmt.Sprint(user[DB.primaryKey("user")])
+		first, err := DB.Table("user").GetByID(id)
+		if err != nil {
+			t.Fatal(dialect, " DB GetByID Error : ", err)
+		}
+		second, err := DB.Table("user").GetByID(id)
+		if err != nil {
+			t.Fatal(dialect, " DB GetByID Error : ", err)
+		}
+
+		first["age"] = 30
+		err = DB.Table("user").Update(first)
+		if err != nil {
+			t.Fatal(dialect, " DB Update Error : ", err)
+		}
+		second["age"] = 40
+		err = DB.Table("user").Update(second)
+		if err != ErrStaleObject {
+			t.Fatal(dialect, " expected ErrStaleObject, got ", err)
+		}
+		first["age"] = 35
+		err = DB.Table("user").Update(first)
+		if err != nil {
+			t.Fatal(dialect, " DB Update Error : ", err)
+		}
+
+		// a failed update keeps the version of the document for the retry
+		_, err = DB.Table("user").Insert(JSONDoc{"name": "jane Doe", "email": "jane@test.com"})
+		if err != nil {
+			t.Fatal(dialect, " DB Create Error : ", err)
+		}
+		first["email"] = "jane@test.com"
+		err = DB.Table("user").Update(first)
+		if err == nil || err == ErrStaleObject {
+			t.Fatal(dialect, " expected unique key violated, got ", err)
+		}
+		first["email"] = "john@test.com"
+		first["age"] = 36
+		err = DB.Table("user").Update(first)
+		if err != nil {
+			t.Fatal(dialect, " DB Update retry Error : ", err)
+		}
+		stored, err := DB.Table("user").GetByID(id)
+		if err != nil || fmt.Sprint(stored["_version"]) != "4" || fmt.Sprint(stored["age"]) != "36" {
+			t.Fatal(dialect, " unexpected record : ", stored, err)
+		}
+		DB.Close()
+	}
+}
+
+func TestSession_Paginate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	modelFile := filepath.Join(dir, "model.json")
+	err = ioutil.WriteFile(modelFile, []byte(sqliteTestModel), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dialect := range []string{"memory", "localdb", "sqlite"} {
+		config := ConfigDB{}
+		config.ModelFile = modelFile
+		config.Type = dialect
+		config.SyncDB = SyncCreate
+		config.Server = filepath.Join(dir, dialect+".db")
+		DB, err := NewOrm(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 1; i <= 5; i++ {
+			_, err = DB.Table("user").Insert(JSONDoc{"name": fmt.Sprintf("user%d", i), "email": fmt.Sprintf("user%d@test.com", i), "age": i * 10})
+			if err != nil {
+				t.Fatal(dialect, " DB Create Error : ", err)
+			}
+		}
+
+		list, err := DB.Table("user").OrderBy("age").Offset(1).Limit(2).Get()
+		if err != nil || len(list) != 2 || list[0]["name"] != "user2" || list[1]["name"] != "user3" {
+			t.Fatal(dialect, " Offset Error : ", list, err)
+		}
+		list, err = DB.Table("user").Where(Gt("age", 10)).OrderBy("age").Offset(1).Limit(2).Get()
+		if err != nil || len(list) != 2 || list[0]["name"] != "user3" || list[1]["name"] != "user4" {
+			t.Fatal(dialect, " Offset with a condition Error : ", list, err)
+		}
+		list, err = DB.Table("user").Limit(0).Get()
+		if err != nil || len(list) != 5 {
+			t.Fatal(dialect, " Limit(0) Error : ", list, err)
+		}
+
+		page, err := DB.Table("user").Where(Gt("age", 10)).OrderBy("age").Paginate(2, 3)
+		if err != nil || len(page.Items) != 1 || page.Items[0]["name"] != "user5" || page.Total != 4 || page.Page != 2 || page.Size != 3 || page.HasNext {
+			t.Fatal(dialect, " Paginate Error : ", page, err)
+		}
+		page, err = DB.Table("user").OrderBy("age").Paginate(1, 3)
+		if err != nil || len(page.Items) != 3 || page.Total != 5 || !page.HasNext {
+			t.Fatal(dialect, " Paginate first page Error : ", page, err)
+		}
+		DB.Close()
+	}
+}
+
+func TestSession_Scroll(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	modelFile := filepath.Join(dir, "model.json")
+	err = ioutil.WriteFile(modelFile, []byte(sqliteTestModel), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dialect := range []string{"memory", "localdb", "sqlite"} {
+		config := ConfigDB{}
+		config.ModelFile = modelFile
+		config.Type = dialect
+		config.SyncDB = SyncCreate
+		config.Server = filepath.Join(dir, dialect+".db")
+		DB, err := NewOrm(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 1; i <= 7; i++ {
+			_, err = DB.Table("user").Insert(JSONDoc{"name": fmt.Sprintf("user%d", i), "email": fmt.Sprintf("user%d@test.com", i), "age": i % 3 * 10})
+			if err != nil {
+				t.Fatal(dialect, " DB Create Error : ", err)
+			}
+		}
+
+		for _, order := range []string{"", "-age"} {
+			seen := map[string]bool{}
+			var names []string
+			cursor := ""
+			for pages := 0; pages < 5; pages++ {
+				list, next, err := DB.Table("user").OrderBy(order).After(cursor).Limit(3).Scroll()
+				if err != nil {
+					t.Fatal(dialect, " Scroll Error : ", err)
+				}
+				for _, doc := range list {
+					name := fmt.Sprint(doc["name"])
+					if seen[name] {
+						t.Fatal(dialect, " Scroll returned twice : ", name)
+					}
+					seen[name] = true
+					names = append(names, name)
+				}
+				if pages == 0 && order == "" {
+					// records inserted while scrolling come last
+					_, err = DB.Table("user").Insert(JSONDoc{"name": "late", "email": "late@test.com", "age": 0})
+					if err != nil {
+						t.Fatal(dialect, " DB Create Error : ", err)
+					}
+				}
+				if next == "" {
+					break
+				}
+				cursor = next
+			}
+			if len(names) != 8 || order == "" && names[7] != "late" || order == "-age" && names[0] != "user2" {
+				t.Fatal(dialect, " Scroll order ", order, " : ", names)
+			}
+		}
+
+		_, _, err = DB.Table("user").OrderBy("name").After("bad cursor").Limit(3).Scroll()
+		if err == nil {
+			t.Fatal(dialect, " expected invalid cursor error")
+		}
+		DB.Close()
+	}
+}
+
+func TestSession_Iterate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	modelFile := filepath.Join(dir, "model.json")
+	err = ioutil.WriteFile(modelFile, []byte(sqliteTestModel), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dialect := range []string{"memory", "localdb", "sqlite"} {
+		config := ConfigDB{}
+		config.ModelFile = modelFile
+		config.Type = dialect
+		config.SyncDB = SyncCreate
+		config.Server = filepath.Join(dir, dialect+".db")
+		DB, err := NewOrm(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var docs []JSONDoc
+		for i := 0; i < 250; i++ {
+			docs = append(docs, JSONDoc{"name": fmt.Sprintf("user%d", i), "email": fmt.Sprintf("user%d@test.com", i), "age": i % 50})
+		}
+		err = DB.Table("user").InsertMany(docs)
+		if err != nil {
+			t.Fatal(dialect, " InsertMany Error : ", err)
+		}
+
+		count := 0
+		err = DB.Table("user").Iterate(func(doc JSONDoc) error {
+			count++
+			return nil
+		})
+		if err != nil || count != 250 {
+			t.Fatal(dialect, " Iterate Error : ", count, err)
+		}
+
+		count = 0
+		stop := fmt.Errorf("stop")
+		err = DB.Table("user").Where(Lt("age", 10)).Iterate(func(doc JSONDoc) error {
+			count++
+			if count == 5 {
+				return stop
+			}
+			return nil
+		})
+		if err != stop || count != 5 {
+			t.Fatal(dialect, " Iterate did not stop : ", count, err)
+		}
+
+		rows, err := DB.Table("user").Where(Lt("age", 10)).Offset(20).Limit(15).Rows()
+		if err != nil {
+			t.Fatal(dialect, " Rows Error : ", err)
+		}
+		count = 0
+		for rows.Next() {
+			if cast.ToInt(rows.Doc()["age"]) >= 10 {
+				t.Fatal(dialect, " Rows filter Error : ", rows.Doc())
+			}
+			count++
+		}
+		if rows.Err() != nil || count != 15 {
+			t.Fatal(dialect, " Rows Error : ", count, rows.Err())
+		}
+		rows.Close()
+
+		if dialect != "sqlite" {
+			// the document dialects release the database between reads
+			err = DB.Table("user").Where(Eq("age", 0)).Iterate(func(doc JSONDoc) error {
+				doc["age"] = 100
+				return DB.Table("user").Update(doc)
+			})
+			i, cerr := DB.Table("user").Where(Eq("age", 100)).Count()
+			if err != nil || cerr != nil || i != 5 {
+				t.Fatal(dialect, " update while iterating : ", i, err, cerr)
+			}
+		}
+		DB.Close()
+	}
+}
+
+func TestSession_GroupBy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	modelFile := filepath.Join(dir, "model.json")
+	err = ioutil.WriteFile(modelFile, []byte(sqliteTestModel), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dialect := range []string{"memory", "localdb", "sqlite"} {
+		config := ConfigDB{}
+		config.ModelFile = modelFile
+		config.Type = dialect
+		config.SyncDB = SyncCreate
+		config.Server = filepath.Join(dir, dialect+".db")
+		DB, err := NewOrm(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var docs []JSONDoc
+		for i, name := range []string{"ann", "bob", "ann", "carl", "bob", "ann"} {
+			docs = append(docs, JSONDoc{"name": name, "email": fmt.Sprintf("%s%d@test.com", name, i), "age": (i + 1) * 10})
+		}
+		err = DB.Table("user").InsertMany(docs)
+		if err != nil {
+			t.Fatal(dialect, " InsertMany Error : ", err)
+		}
+
+		groups, err := DB.Table("user").GroupBy("name").Sum("age").Count().Avg("age").Max("age", "oldest").OrderBy("name").Get()
+		if err != nil || len(groups) != 3 {
+			t.Fatal(dialect, " GroupBy Error : ", groups, err)
+		}
+		ann := groups[0]
+		if ann["name"] != "ann" || cast.ToInt(ann["sum_age"]) != 100 || cast.ToInt(ann["count"]) != 3 ||
+			cast.ToFloat64(ann["avg_age"]) < 33.3 || cast.ToFloat64(ann["avg_age"]) > 33.4 || cast.ToInt(ann["oldest"]) != 60 {
+			t.Fatal(dialect, " GroupBy values : ", ann)
+		}
+
+		groups, err = DB.Table("user").Where(Gt("age", 10)).GroupBy("name").Count().Having(Gte("count", 2)).OrderBy("-count").Get()
+		if err != nil || len(groups) != 2 || groups[0]["name"] != "bob" && groups[0]["name"] != "ann" || cast.ToInt(groups[0]["count"]) != 2 {
+			t.Fatal(dialect, " GroupBy Having Error : ", groups, err)
+		}
+
+		groups, err = DB.Table("user").GroupBy().Min("age", "youngest").Count().Get()
+		if err != nil || len(groups) != 1 || cast.ToInt(groups[0]["youngest"]) != 10 || cast.ToInt(groups[0]["count"]) != 6 {
+			t.Fatal(dialect, " GroupBy all Error : ", groups, err)
+		}
+		total, err := DB.dialectDB.GetByGroup("user", map[string]interface{}{
+			"$group": map[string]interface{}{"_id": nil, "total": map[string]interface{}{"$sum": "$age"}},
+		})
+		if err != nil || cast.ToInt(total["total"]) != 210 {
+			t.Fatal(dialect, " GetByGroup Error : ", total, err)
+		}
+		DB.Close()
+	}
+}
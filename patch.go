@@ -0,0 +1,172 @@
+package gorgo
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/spf13/cast"
+)
+
+// FieldOp - change of one field of a record, applied atomically by Session.Patch
+type FieldOp struct {
+	op    string
+	field string
+	value interface{}
+}
+
+// Set - set the field to value
+func Set(field string, value interface{}) FieldOp {
+	return FieldOp{op: "$set", field: field, value: value}
+}
+
+// Inc - add n to the numeric field, a missing field counts as 0
+func Inc(field string, n interface{}) FieldOp {
+	return FieldOp{op: "$inc", field: field, value: n}
+}
+
+// Push - append value to the array field, a missing field starts empty
+func Push(field string, value interface{}) FieldOp {
+	return FieldOp{op: "$push", field: field, value: value}
+}
+
+// Unset - remove the field, a sql column is set to NULL
+func Unset(field string) FieldOp {
+	return FieldOp{op: "$unset", field: field}
+}
+
+// PatchDialect - dialect changing some fields of a record in one atomic
+// write. Session.Patch falls back to a read and an Update in a transaction
+// for the other dialects.
+type PatchDialect interface {
+	Patch(tableName string, id string, ops []FieldOp) error
+}
+
+// Patch - change only the fields of data and those of the operations, ex:
+//
+//	Patch(id, JSONDoc{"name": "john"}, Inc("stock", -1), Push("tags", "new"), Unset("promo"))
+//
+// The model validation applies to the set and unset fields only. With update
+// hooks the record is read, patched and updated in a transaction, the hooks
+// get the whole record. A soft deleted record is not found, unless
+// WithDeleted is set.
+func (s *Session) Patch(id string, data JSONDoc, ops ...FieldOp) error {
+	if s.tableName == "" {
+		return fmt.Errorf("need to set a tablename")
+	}
+	stamps := JSONDoc{}
+	s.stamp(stamps, false)
+	var all []FieldOp
+	for _, doc := range []JSONDoc{data, stamps} {
+		for _, k := range sortMap(doc) {
+			all = append(all, Set(k, doc[k]))
+		}
+	}
+	all = append(all, ops...)
+	if len(data) == 0 && len(ops) == 0 {
+		return nil
+	}
+
+	hooks := s.orm.hooks.has(s.tableName, beforeUpdate, afterUpdate)
+	if pd, ok := s.orm.dialectDB.(PatchDialect); ok && !hooks && !s.hidesDeleted() {
+		return pd.Patch(s.tableName, id, all)
+	}
+	return s.atomic(func(s *Session) error {
+		doc, err := s.orm.dialectDB.GetById(s.tableName, id)
+		if err != nil {
+			return err
+		}
+		if s.hidesDeleted() && doc[deletedField] != nil {
+			return ErrNotFound
+		}
+		if pd, ok := s.orm.dialectDB.(PatchDialect); ok && !hooks {
+			return pd.Patch(s.tableName, id, all)
+		}
+		err = applyOps(doc, all)
+		if err == nil {
+			err = s.orm.hooks.run(s, beforeUpdate, doc)
+		}
+		if err == nil {
+			err = s.orm.dialectDB.Update(s.tableName, doc)
+		}
+		if err != nil {
+			return err
+		}
+		return s.orm.hooks.run(s, afterUpdate, doc)
+	})
+}
+
+// changedFields - values the operations give to the set and unset fields
+func changedFields(ops []FieldOp) JSONDoc {
+	changes := JSONDoc{}
+	for _, op := range ops {
+		switch op.op {
+		case "$set":
+			changes[op.field] = op.value
+		case "$unset":
+			changes[op.field] = nil
+		}
+	}
+	return changes
+}
+
+// applyOps - apply the operations to the document
+func applyOps(doc JSONDoc, ops []FieldOp) error {
+	for _, op := range ops {
+		switch op.op {
+		case "$set":
+			doc[op.field] = op.value
+		case "$unset":
+			delete(doc, op.field)
+		case "$inc":
+			sum, err := addNumbers(doc[op.field], op.value)
+			if err != nil {
+				return fmt.Errorf("Inc %s: %v", op.field, err)
+			}
+			doc[op.field] = sum
+		case "$push":
+			var list []interface{}
+			if current := doc[op.field]; current != nil {
+				rv := reflect.ValueOf(current)
+				if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+					return fmt.Errorf("Push %s: the field is not an array", op.field)
+				}
+				for i := 0; i < rv.Len(); i++ {
+					list = append(list, rv.Index(i).Interface())
+				}
+			}
+			doc[op.field] = append(list, op.value)
+		default:
+			return fmt.Errorf("Unknown field operation %s", op.op)
+		}
+	}
+	return nil
+}
+
+// addNumbers - sum of a stored number, nil for 0, and n. Integers stay
+// integers, json decoded numbers are float64.
+func addNumbers(current interface{}, n interface{}) (interface{}, error) {
+	if current == nil {
+		current = 0
+	}
+	if isInteger(current) && isInteger(n) {
+		return cast.ToInt64(current) + cast.ToInt64(n), nil
+	}
+	a, err := cast.ToFloat64E(current)
+	if err != nil {
+		return nil, fmt.Errorf("%v is not a number", current)
+	}
+	b, err := cast.ToFloat64E(n)
+	if err != nil {
+		return nil, fmt.Errorf("%v is not a number", n)
+	}
+	return a + b, nil
+}
+
+func isInteger(v interface{}) bool {
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,156 @@
+package gorgo
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cast"
+)
+
+func TestSession_Patch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	modelFile := filepath.Join(dir, "model.json")
+	err = ioutil.WriteFile(modelFile, []byte(sqliteTestModel), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dialect := range []string{"memory", "localdb", "sqlite"} {
+		config := ConfigDB{}
+		config.ModelFile = modelFile
+		config.Type = dialect
+		config.SyncDB = SyncCreate
+		config.Server = filepath.Join(dir, dialect+".db")
+		DB, err := NewOrm(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		user, err := DB.Table("user").Insert(JSONDoc{"name": "john Doe", "email": "john@test.com", "age": 20})
+		if err != nil {
+			t.Fatal(dialect, " DB Create Error : ", err)
+		}
+		id := fmt.Sprint(user[DB.primaryKey("user")])
+
+		err = DB.Table("user").Patch(id, JSONDoc{"name": "jack"}, Inc("age", 2))
+		if err != nil {
+			t.Fatal(dialect, " Patch Error : ", err)
+		}
+		stored, err := DB.Table("user").GetByID(id)
+		if err != nil || stored["name"] != "jack" || cast.ToInt(stored["age"]) != 22 || stored["email"] != "john@test.com" {
+			t.Fatal(dialect, " Patch not applied : ", stored, err)
+		}
+
+		err = DB.Table("user").Patch(id, JSONDoc{"email": "not an email"})
+		if err == nil {
+			t.Fatal(dialect, " expected validation error on a patched field")
+		}
+		err = DB.Table("user").Patch(id, nil, Unset("age"))
+		if err != nil {
+			t.Fatal(dialect, " Unset Error : ", err)
+		}
+		stored, err = DB.Table("user").GetByID(id)
+		if err != nil || stored["age"] != nil || stored["name"] != "jack" {
+			t.Fatal(dialect, " Unset not applied : ", stored, err)
+		}
+
+		err = DB.Table("user").Patch(id, nil, Push("tags", "new"), Push("tags", "vip"))
+		if dialect == "sqlite" {
+			if err == nil {
+				t.Fatal(dialect, " expected Push error on a sql column")
+			}
+		} else {
+			stored, _ = DB.Table("user").GetByID(id)
+			if err != nil || len(cast.ToSlice(stored["tags"])) != 2 {
+				t.Fatal(dialect, " Push not applied : ", stored, err)
+			}
+		}
+
+		err = DB.Table("user").Patch("999", nil, Inc("age", 1))
+		if err == nil {
+			t.Fatal(dialect, " expected error patching a missing record")
+		}
+		DB.Close()
+	}
+}
+
+func TestSession_PatchHooksSoftDelete(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	modelFile := filepath.Join(dir, "model.json")
+	model := strings.Replace(sqliteTestModel, `"name": "user",`, `"name": "user", "softDelete": true,`, 1)
+	err = ioutil.WriteFile(modelFile, []byte(model), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dialect := range []string{"memory", "localdb", "sqlite"} {
+		config := ConfigDB{}
+		config.ModelFile = modelFile
+		config.Type = dialect
+		config.SyncDB = SyncCreate
+		config.Server = filepath.Join(dir, dialect+".db")
+		DB, err := NewOrm(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		user, err := DB.Table("user").Insert(JSONDoc{"name": "john Doe", "email": "john@test.com", "age": 20})
+		if err != nil {
+			t.Fatal(dialect, " DB Create Error : ", err)
+		}
+		id := fmt.Sprint(user[DB.primaryKey("user")])
+
+		// no hook, a soft deleted record is not found
+		err = DB.Table("user").DeleteByID(id)
+		if err != nil {
+			t.Fatal(dialect, " DB Delete Error : ", err)
+		}
+		err = DB.Table("user").Patch(id, JSONDoc{"name": "jack"})
+		if err != ErrNotFound {
+			t.Fatal(dialect, " expected ErrNotFound patching a soft deleted record, got ", err)
+		}
+		err = DB.Table("user").WithDeleted().Patch(id, JSONDoc{"name": "jack"})
+		if err != nil {
+			t.Fatal(dialect, " Patch WithDeleted Error : ", err)
+		}
+		err = DB.Table("user").Restore(id)
+		if err != nil {
+			t.Fatal(dialect, " Restore Error : ", err)
+		}
+
+		var before, after JSONDoc
+		DB.OnBeforeUpdate("user", func(s *Session, doc JSONDoc) error {
+			before = copyDoc(doc)
+			doc["age"] = 99
+			return nil
+		})
+		DB.OnAfterUpdate("user", func(s *Session, doc JSONDoc) error {
+			after = doc
+			return nil
+		})
+		err = DB.Table("user").Patch(id, nil, Inc("age", 2))
+		if err != nil {
+			t.Fatal(dialect, " Patch Error : ", err)
+		}
+		if cast.ToInt(before["age"]) != 22 || before["email"] != "john@test.com" || after == nil {
+			t.Fatal(dialect, " update hooks without the patched record : ", before, after)
+		}
+		stored, err := DB.Table("user").GetByID(id)
+		if err != nil || cast.ToInt(stored["age"]) != 99 || stored["name"] != "jack" {
+			t.Fatal(dialect, " before update hook not applied : ", stored, err)
+		}
+		DB.Close()
+	}
+}
@@ -0,0 +1,191 @@
+package gorgo
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// stampedJSON - json argument holding the fields and the default timestamps
+type stampedJSON JSONDoc
+
+func (j stampedJSON) Match(v driver.Value) bool {
+	var doc JSONDoc
+	if s, ok := v.(string); !ok || json.Unmarshal([]byte(s), &doc) != nil {
+		return false
+	}
+	for k, value := range j {
+		if fmt.Sprint(doc[k]) != fmt.Sprint(value) {
+			return false
+		}
+	}
+	return doc["_created"] != nil && doc["_updated"] != nil
+}
+
+func newMockPostgres(t *testing.T) (*ORM, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &model{Tables: map[string]table{
+		"user": {Name: "user", Fields: []*field{{Name: "_id", Type: "bigint", Autoincrement: true}}},
+	}}
+	dialect := &PostgresDialect{}
+	dialect.DB = db
+	dialect.Model = m
+	dialect.Config = ConfigDB{Validations: GetFunctions()}
+	dialect.syntax()
+	return &ORM{dialectDB: dialect}, mock
+}
+
+func TestPostgresDialect_Create(t *testing.T) {
+	DB, mock := newMockPostgres(t)
+	defer DB.Close()
+
+	mock.ExpectQuery(`INSERT INTO "user" ("age","email","name") VALUES ($1,$2,$3) RETURNING "_id"`).
+		WithArgs(25, "jd@test.com", "john Doe").
+		WillReturnRows(sqlmock.NewRows([]string{"_id"}).AddRow(int64(3)))
+
+	user := JSONDoc{"name": "john Doe", "email": "jd@test.com", "age": 25}
+	userRet, err := DB.Table("user").Insert(user)
+	if err != nil {
+		t.Fatal("DB Create Error : ", err)
+	}
+	if userRet["_id"] != int64(3) {
+		t.Fatalf("expected _id 3, got %v", userRet["_id"])
+	}
+
+	mock.ExpectQuery(`SELECT * FROM "user" WHERE age > $1 AND name = $2`).
+		WithArgs(18, "john Doe").
+		WillReturnRows(sqlmock.NewRows([]string{"_id", "name"}).AddRow(int64(3), "john Doe"))
+
+	list, err := DB.Table("user").Where("age > ? AND name = ?", 18, "john Doe").Get()
+	if err != nil {
+		t.Fatal("DB GetManyByQuery Error : ", err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(list))
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPostgresDialect_Schemaless(t *testing.T) {
+	DB, mock := newMockPostgres(t)
+	defer DB.Close()
+
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS "event" ("id" BIGSERIAL PRIMARY KEY, "data" JSONB NOT NULL)`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`INSERT INTO "event" ("data") VALUES ($1) RETURNING "id"`).
+		WithArgs(stampedJSON{"kind": "login", "user": "john"}).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(1)))
+
+	_, err := DB.Table("event").Insert(JSONDoc{"kind": "login", "user": "john"})
+	if err != nil {
+		t.Fatal("DB Create Error : ", err)
+	}
+
+	rows := sqlmock.NewRowsWithColumnDefinition(
+		sqlmock.NewColumn("id").OfType("INT8", int64(0)),
+		sqlmock.NewColumn("data").OfType("JSONB", []byte{}),
+	).AddRow(int64(1), []byte(`{"kind":"login","user":"john"}`))
+	mock.ExpectQuery(`SELECT * FROM "event" WHERE "data"->>'user' ILIKE $1 ORDER BY "data"->'kind' DESC LIMIT 10`).
+		WithArgs("%JOHN%").
+		WillReturnRows(rows)
+
+	list, err := DB.dialectDB.GetAllBySearch("event", "JOHN", "user", 1, 10, Query{Order: "-kind"})
+	if err != nil {
+		t.Fatal("DB GetAllBySearch Error : ", err)
+	}
+	if len(list) != 1 || list[0]["id"] != int64(1) || list[0]["user"] != "john" || list[0]["kind"] != "login" {
+		t.Fatalf("unexpected result %v", list)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPostgresDialect_UpsertVersioned(t *testing.T) {
+	DB, mock := newMockPostgres(t)
+	defer DB.Close()
+	DB.dialectDB.(*PostgresDialect).Model.Tables["product"] = table{
+		Name:      "product",
+		Fields:    []*field{{Name: "_id", Type: "bigint", Autoincrement: true}, {Name: "sku", Type: "varchar", Unique: true}, {Name: "stock", Type: "int"}},
+		Versioned: true,
+	}
+
+	mock.ExpectExec(`INSERT INTO "product" ("_version","sku","stock") VALUES ($1,$2,$3) ON CONFLICT ("sku") DO UPDATE SET "sku" = excluded."sku", "stock" = excluded."stock", "_version" = COALESCE("product"."_version", 0) + 1`).
+		WithArgs(1, "a1", 9).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT * FROM "product" WHERE ("sku" = $1) LIMIT 1`).WithArgs("a1").
+		WillReturnRows(sqlmock.NewRows([]string{"_id", "sku", "stock", "_version"}).AddRow(10, "a1", 9, 2))
+	stored, err := DB.Table("product").Upsert(JSONDoc{"sku": "a1", "stock": 9}, "sku")
+	if err != nil || fmt.Sprint(stored["_version"]) != "2" {
+		t.Fatal("Upsert Error : ", stored, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPostgresDialect_SchemalessNumbers(t *testing.T) {
+	DB, mock := newMockPostgres(t)
+	defer DB.Close()
+
+	rows := sqlmock.NewRowsWithColumnDefinition(
+		sqlmock.NewColumn("id").OfType("INT8", int64(0)),
+		sqlmock.NewColumn("data").OfType("JSONB", []byte{}),
+	).AddRow(int64(2), []byte(`{"city":"Rio","amount":10}`)).AddRow(int64(1), []byte(`{"city":"Rio","amount":9.5}`))
+	mock.ExpectQuery(`SELECT * FROM "event" WHERE (("data"->>'amount')::numeric >= $1 AND "data"->>'city' = $2) ORDER BY "data"->'amount' DESC`).
+		WithArgs(9, "Rio").
+		WillReturnRows(rows)
+	list, err := DB.Table("event").Where(And(Gte("amount", 9), Eq("city", "Rio"))).OrderBy("-amount").Get()
+	if err != nil || len(list) != 2 {
+		t.Fatal("DB Get Error : ", list, err)
+	}
+
+	mock.ExpectQuery(`SELECT * FROM (SELECT "data"->>'city' AS "city", SUM(("data"->>'amount')::numeric) AS "total", AVG(("data"->>'amount')::numeric) AS "avg_amount" FROM "event" GROUP BY "data"->>'city') AS g ORDER BY "total" DESC`).
+		WillReturnRows(sqlmock.NewRows([]string{"city", "total", "avg_amount"}).AddRow("Rio", 19.5, 9.75))
+	groups, err := DB.Table("event").GroupBy("city").Sum("amount", "total").Avg("amount").OrderBy("-total").Get()
+	if err != nil || len(groups) != 1 || groups[0]["city"] != "Rio" {
+		t.Fatal("GroupBy Error : ", groups, err)
+	}
+
+	mock.ExpectQuery(`SELECT * FROM (SELECT "data"->>'city' AS "city", MIN(("data"->>'amount')::numeric) AS "min_amount", MAX(("data"->>'amount')::numeric) AS "max_amount" FROM "event" GROUP BY "data"->>'city') AS g`).
+		WillReturnRows(sqlmock.NewRows([]string{"city", "min_amount", "max_amount"}).AddRow("Rio", 9.5, 10))
+	groups, err = DB.Table("event").GroupBy("city").Min("amount").Max("amount").Get()
+	if err != nil || len(groups) != 1 || fmt.Sprint(groups[0]["max_amount"]) != "10" {
+		t.Fatal("GroupBy Error : ", groups, err)
+	}
+
+	mock.ExpectQuery(`SELECT "data"->>'city' AS "_id", MAX(("data"->>'amount')::numeric) AS "high", MIN(("data"->>'amount')::numeric) AS "low", SUM(("data"->>'amount')::numeric) AS "total" FROM "event" GROUP BY "data"->>'city' LIMIT 1`).
+		WillReturnRows(sqlmock.NewRows([]string{"_id", "high", "low", "total"}).AddRow("Rio", 10, 9.5, 19.5))
+	group, err := DB.dialectDB.GetByGroup("event", map[string]interface{}{"$group": map[string]interface{}{
+		"_id":   "$city",
+		"total": map[string]interface{}{"$sum": "$amount"},
+		"low":   map[string]interface{}{"$min": "$amount"},
+		"high":  map[string]interface{}{"$max": "$amount"},
+	}})
+	if err != nil || group["_id"] != "Rio" {
+		t.Fatal("GetByGroup Error : ", group, err)
+	}
+
+	cursor, err := encodeCursor(parseOrder("amount,id"), JSONDoc{"id": int64(2), "amount": 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.ExpectQuery(`SELECT * FROM "event" WHERE (("data"->>'amount')::numeric > $1 OR (("data"->>'amount')::numeric = $2 AND "id" > $3)) ORDER BY "data"->'amount' ASC, "id" ASC LIMIT 10`).
+		WithArgs(int64(10), int64(10), int64(2)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "data"}))
+	_, _, err = DB.Table("event").OrderBy("amount").After(cursor).Limit(10).Scroll()
+	if err != nil {
+		t.Fatal("Scroll Error : ", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,112 @@
+package gorgo
+
+import (
+	"context"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	_ "github.com/lib/pq" // Package for postgres driver
+	"github.com/spf13/cast"
+)
+
+//PostgresDialect - dialect for postgresql database. Tables declared in the
+//model are plain tables, any other table keeps its documents in a JSONB column.
+type PostgresDialect struct {
+	sqlDialect
+}
+
+func init() {
+	RegisterDialect("postgres", func() Dialect { return &PostgresDialect{} })
+}
+
+// syntax - set the postgres flavor of the shared sql dialect
+func (p *PostgresDialect) syntax() {
+	p.quote = quoteANSI
+	p.placeholder = sq.Dollar
+	p.like = "ILIKE"
+	p.returning = true
+	p.jsonColumn = "data"
+	p.jsonType = "JSONB"
+	p.serialType = "BIGSERIAL"
+	// ->> reads the text of a field, numbers are cast to be compared and
+	// accumulated as numbers, and sorted as jsonb values with ->
+	p.jsonField = func(column string, field string) string {
+		return column + "->>" + quoteLiteral(field)
+	}
+	p.jsonNumber = func(expr string) string {
+		return "(" + expr + ")::numeric"
+	}
+	p.jsonOrder = func(column string, field string) string {
+		return column + "->" + quoteLiteral(field)
+	}
+	p.columnType = postgresType
+	p.columnsQuery = "SELECT attname, format_type(atttypid, atttypmod) FROM pg_attribute" +
+		" WHERE attrelid = to_regclass(quote_ident($1)) AND attnum > 0 AND NOT attisdropped"
+	p.indexesQuery = "SELECT indexname FROM pg_indexes WHERE schemaname = current_schema() AND tablename = $1"
+	p.upsert = onConflict
+	p.alterType = func(table string, column string, columnType string) string {
+		return "ALTER TABLE " + table + " ALTER COLUMN " + column + " TYPE " + columnType + " USING " + column + "::" + columnType
+	}
+}
+
+//InitDB  - initialize database
+func (p *PostgresDialect) InitDB(config ConfigDB) error {
+	p.syntax()
+
+	port := config.Port
+	if port == 0 {
+		port = 5432
+	}
+	sslmode := "disable"
+	if config.UseSSL {
+		sslmode = "require"
+	}
+	dsn := "host=" + pgParam(config.Server) +
+		" port=" + cast.ToString(port) +
+		" user=" + pgParam(config.User) +
+		" password=" + pgParam(config.Password) +
+		" dbname=" + pgParam(config.Database) +
+		" sslmode=" + sslmode
+	return p.open("postgres", dsn, config)
+}
+
+//WithContext  - copy of the dialect running its statements with ctx
+func (p *PostgresDialect) WithContext(ctx context.Context) Dialect {
+	dst := &PostgresDialect{}
+	p.withContext(&dst.sqlDialect, ctx)
+	return dst
+}
+
+//Begin  - open a transaction, the returned dialect runs every call inside it
+func (p *PostgresDialect) Begin() (TxDialect, error) {
+	txd := &PostgresDialect{}
+	err := p.begin(&txd.sqlDialect)
+	if err != nil {
+		return nil, err
+	}
+	return txd, nil
+}
+
+// pgParam - quote a connection string value
+func pgParam(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// postgresType - column type for a model field type, as format_type names it
+func postgresType(fieldType string) string {
+	switch fieldType {
+	case "int":
+		return "integer"
+	case "bigint":
+		return "bigint"
+	case "float":
+		return "real"
+	case "double":
+		return "double precision"
+	case "date":
+		return "timestamp"
+	case "varchar":
+		return "character varying(255)"
+	}
+	return "text"
+}
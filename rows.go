@@ -0,0 +1,121 @@
+package gorgo
+
+// Rows - records of a query read one at a time, ex:
+//
+//	rows, err := db.Table("user").Where(Gt("age", 18)).Rows()
+//	if err != nil {
+//		return err
+//	}
+//	defer rows.Close()
+//	for rows.Next() {
+//		doc := rows.Doc()
+//		...
+//	}
+//	return rows.Err()
+type Rows struct {
+	next  func() (JSONDoc, error)
+	close func() error
+	doc   JSONDoc
+	err   error
+	done  bool
+}
+
+// StreamDialect - dialect reading the records of a query one at a time,
+// without loading them all
+type StreamDialect interface {
+	Stream(tableName string, q Query) (*Rows, error)
+}
+
+// newRows - rows returned by next until it returns nil, close releases the
+// resources of the read
+func newRows(next func() (JSONDoc, error), close func() error) *Rows {
+	return &Rows{next: next, close: close}
+}
+
+// listRows - rows of documents already read
+func listRows(list []JSONDoc) *Rows {
+	return newRows(func() (JSONDoc, error) {
+		if len(list) == 0 {
+			return nil, nil
+		}
+		doc := list[0]
+		list = list[1:]
+		return doc, nil
+	}, nil)
+}
+
+// Next - read the next record, false after the last one or on error
+func (r *Rows) Next() bool {
+	if r.done {
+		return false
+	}
+	r.doc, r.err = r.next()
+	if r.err != nil || r.doc == nil {
+		r.doc = nil
+		r.Close()
+		return false
+	}
+	return true
+}
+
+// Doc - the record read by Next
+func (r *Rows) Doc() JSONDoc {
+	return r.doc
+}
+
+// Err - error that stopped Next, nil at the end of the records
+func (r *Rows) Err() error {
+	return r.err
+}
+
+// Close - release the read, it may be called several times
+func (r *Rows) Close() error {
+	if r.done {
+		return nil
+	}
+	r.done = true
+	if r.close == nil {
+		return nil
+	}
+	err := r.close()
+	if r.err == nil {
+		r.err = err
+	}
+	return err
+}
+
+// Rows - iterator over the records of the query, streamed by the dialects
+// able to do it. The caller must Close it. The sql dialects hold a
+// connection, or the transaction, until then.
+func (s *Session) Rows() (*Rows, error) {
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	q := s.query()
+	q.Offset, q.Limit = s.offset, s.limit
+	if sd, ok := s.orm.dialectDB.(StreamDialect); ok {
+		return sd.Stream(s.tableName, q)
+	}
+	list, err := s.Get()
+	if err != nil {
+		return nil, err
+	}
+	return listRows(list), nil
+}
+
+// Iterate - call fn with each record of the query, stopping at the first
+// error of fn, which is returned
+func (s *Session) Iterate(fn func(doc JSONDoc) error) error {
+	rows, err := s.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		err = fn(rows.Doc())
+		if err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
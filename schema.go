@@ -0,0 +1,88 @@
+package gorgo
+
+import (
+	"fmt"
+	"log"
+)
+
+// Modes of ConfigDB.SyncDB, NewOrm reconciles the database with the model
+// when it is set
+const (
+	// SyncCreate - create the missing tables, columns and indexes, the other
+	// differences are only reported
+	SyncCreate = "create"
+	// SyncFull - like SyncCreate, also drop the columns removed from the model
+	// and change the column types
+	SyncFull = "full"
+	// SyncDryRun - report the planned changes without touching the database
+	SyncDryRun = "dryrun"
+)
+
+// SchemaChange - difference between the model and the database
+type SchemaChange struct {
+	Table  string
+	Column string
+	// Action - "create table", "add column", "alter column", "drop column" or "create index"
+	Action string
+	// Detail - statement or index run for the change, or why it can not be made
+	Detail string
+	// Applied - the change was made, false in dry run, for the changes losing
+	// data outside SyncFull and for those the database can not make
+	Applied bool
+}
+
+func (c SchemaChange) String() string {
+	s := c.Action + " " + c.Table
+	if c.Column != "" {
+		s += "." + c.Column
+	}
+	if c.Detail != "" {
+		s += ": " + c.Detail
+	}
+	if !c.Applied {
+		s += " (not applied)"
+	}
+	return s
+}
+
+// destructive - the change may lose data, it is only made in SyncFull
+func (c SchemaChange) destructive() bool {
+	return c.Action == "alter column" || c.Action == "drop column"
+}
+
+// appliesIn - true when the change is made in mode
+func (c SchemaChange) appliesIn(mode string) bool {
+	return mode == SyncFull || mode == SyncCreate && !c.destructive()
+}
+
+func validSyncMode(mode string) error {
+	switch mode {
+	case SyncCreate, SyncFull, SyncDryRun:
+		return nil
+	}
+	return fmt.Errorf("Unknown SyncDB mode %q, expected %s, %s or %s", mode, SyncCreate, SyncFull, SyncDryRun)
+}
+
+// SyncSchema - reconcile the database with the model and return the
+// differences found, the applied ones flagged. Dialects without schema,
+// like memory, have nothing to reconcile.
+func (d *ORM) SyncSchema(mode string) ([]SchemaChange, error) {
+	err := validSyncMode(mode)
+	if err != nil {
+		return nil, err
+	}
+	syncer, ok := d.dialectDB.(SchemaSyncer)
+	if !ok {
+		return nil, nil
+	}
+	return syncer.SyncSchema(mode)
+}
+
+// syncDB - run the ConfigDB.SyncDB reconciliation of NewOrm, logging the changes
+func (d *ORM) syncDB(mode string) error {
+	changes, err := d.SyncSchema(mode)
+	for _, change := range changes {
+		log.Println("SYNC=", change)
+	}
+	return err
+}
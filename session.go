@@ -1,19 +1,44 @@
 package gorgo
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotFound - returned by First when no record matches
+var ErrNotFound = errors.New("record not found")
+
+// ErrStaleObject - returned by Update on a versioned table when the record
+// changed since the document was read
+var ErrStaleObject = errors.New("stale object, the record was changed since it was read")
+
+// ErrTxNotSupported - returned by Transaction when the dialect has no transactions
+var ErrTxNotSupported = errors.New("transactions are not supported by the dialect")
 
 type Session struct {
 	tableName string
 	limit     int
 	offset    int
 	where     string
+	cond      Cond
 	order     string
 	params    []interface{}
-	colums    string
+	columns   []string
 	pk        string
 	join      string
 	groupBy   string
 	orm       *ORM
+	err       error
+	// withDeleted - reads include the soft deleted records
+	withDeleted bool
+	// purging - deletes remove the records of soft delete tables
+	purging bool
+	// actor - user recorded in the audit fields, see As
+	actor interface{}
+	// after - cursor of the records read by Scroll
+	after string
 }
 
 func (s *Session) Init() {
@@ -22,76 +47,385 @@ func (s *Session) Init() {
 	s.order = ""
 }
 
-func (s *Session) Where(query string, params ...interface{}) *Session {
-	s.where = query
-	s.params = params
+// WithContext - run the calls of the session with ctx, ex: the context of an
+// http request, so they are cancelled with it or fail after its deadline
+func (s *Session) WithContext(ctx context.Context) *Session {
+	s.orm = s.orm.WithContext(ctx)
+	return s
+}
+
+// Table - new session over the table, running in the same transaction and context
+func (s *Session) Table(tbl string) *Session {
+	return s.orm.Table(tbl)
+}
+
+// Where - filter the records by a native query of the dialect with its params,
+// ex: Where("age > ?", 18) on sql, or by a portable Cond, ex: Where(Gt("age", 18))
+func (s *Session) Where(query interface{}, params ...interface{}) *Session {
+	switch q := query.(type) {
+	case string:
+		s.where = q
+		s.params = params
+	case Cond:
+		s.cond = q
+	default:
+		s.err = fmt.Errorf("Where needs a string or a Cond, received %T", query)
+	}
 	return s
 }
 
+// filtered - true when Where was called with a query or a condition
+func (s *Session) filtered() bool {
+	return s.where != "" || !s.cond.empty()
+}
+
+// WithDeleted - include the soft deleted records in the reads
+func (s *Session) WithDeleted() *Session {
+	s.withDeleted = true
+	return s
+}
+
+// softDelete - true when the model table keeps its deleted records
+func (s *Session) softDelete() bool {
+	md, ok := s.orm.dialectDB.(modelDialect)
+	if !ok {
+		return false
+	}
+	t, ok := md.dbModel().Tables[s.tableName]
+	return ok && t.SoftDelete
+}
+
+// hidesDeleted - true when the reads skip the soft deleted records
+func (s *Session) hidesDeleted() bool {
+	return !s.withDeleted && s.softDelete()
+}
+
+// scoped - true when the reads need a query, from Where or the soft delete
+func (s *Session) scoped() bool {
+	return s.filtered() || s.hidesDeleted()
+}
+
+// check - error of the session setup
+func (s *Session) check() error {
+	if s.tableName == "" {
+		return fmt.Errorf("need to set a tablename")
+	}
+	return s.err
+}
+
+// Limit - return at most i records, 0 for all of them
 func (s *Session) Limit(i int) *Session {
 	s.limit = i
 	return s
 }
 
+// Offset - skip the first i records, ex: Offset(20).Limit(10) returns the
+// records 21 to 30
 func (s *Session) Offset(i int) *Session {
 	s.offset = i
 	return s
 }
 
+// OrderBy - sort the records, ex: "name desc, age asc"
+func (s *Session) OrderBy(order string) *Session {
+	s.order = order
+	return s
+}
+
+// Select - return only the given columns, the id is always returned
+func (s *Session) Select(columns ...string) *Session {
+	s.columns = columns
+	return s
+}
+
+func (s *Session) query() Query {
+	q := Query{Where: s.where, Params: s.params, Cond: s.cond, Order: s.order, Columns: s.columns}
+	if s.hidesDeleted() {
+		if q.Cond.empty() {
+			q.Cond = IsNull(deletedField)
+		} else {
+			q.Cond = And(q.Cond, IsNull(deletedField))
+		}
+	}
+	return q
+}
+
 func (s *Session) Get() ([]JSONDoc, error) {
-	if s.tableName == "" {
-		return []JSONDoc{}, fmt.Errorf("need to set a tablename")
+	if err := s.check(); err != nil {
+		return []JSONDoc{}, err
+	}
+	if s.scoped() {
+		q := s.query()
+		q.Offset, q.Limit = s.offset, s.limit
+		return s.orm.dialectDB.GetManyByQuery(s.tableName, q)
 	}
-	if s.where != "" {
-		return s.orm.dialectDB.GetManyByQuery(s.tableName, s.where, s.params...)
+	return s.orm.dialectDB.GetAll(s.tableName, s.offset, s.limit, s.query())
+}
+
+// Page - records of a page and where it stands in the query, see Paginate
+type Page struct {
+	Items   []JSONDoc
+	Total   int
+	Page    int
+	Size    int
+	HasNext bool
+}
+
+// Paginate - records of the page, counted from 1, of size records and the
+// total of the query. The Offset and Limit of the session are ignored.
+func (s *Session) Paginate(page int, size int) (Page, error) {
+	if size < 1 {
+		return Page{}, fmt.Errorf("page size must be positive")
+	}
+	if page < 1 {
+		page = 1
+	}
+	ps := *s
+	ps.offset, ps.limit = (page-1)*size, size
+	items, err := ps.Get()
+	if err != nil {
+		return Page{}, err
+	}
+	total, err := s.Count()
+	if err != nil {
+		return Page{}, err
 	}
-	return s.orm.dialectDB.GetAll(s.tableName, s.offset, s.limit, s.order)
+	return Page{Items: items, Total: total, Page: page, Size: size, HasNext: page*size < total}, nil
 }
 
+// Find - run the query and decode the records into dest, a pointer to a
+// slice of structs. Fields are matched by their gorgo, json or bson tag.
+func (s *Session) Find(dest interface{}) error {
+	list, err := s.Get()
+	if err != nil {
+		return err
+	}
+	return decodeDocs(list, dest)
+}
+
+// First - run the query and decode the first record into dest, a pointer to
+// a struct. Returns ErrNotFound when nothing matches.
+func (s *Session) First(dest interface{}) error {
+	list, err := s.Get()
+	if err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		return ErrNotFound
+	}
+	return decodeDocs(list[:1], dest)
+}
+
+// GetByID - the record with the id, ErrNotFound when it is soft deleted
 func (s *Session) GetByID(id string) (JSONDoc, error) {
 	if s.tableName == "" {
 		return JSONDoc{}, fmt.Errorf("need to set a tablename")
 	}
-	return s.orm.dialectDB.GetById(s.tableName, id)
+	if !s.hidesDeleted() {
+		return s.orm.dialectDB.GetById(s.tableName, id, s.columns...)
+	}
+	columns := s.columns
+	if len(columns) > 0 {
+		columns = append(columns[:len(columns):len(columns)], deletedField)
+	}
+	doc, err := s.orm.dialectDB.GetById(s.tableName, id, columns...)
+	if err != nil {
+		return doc, err
+	}
+	if doc[deletedField] != nil {
+		return nil, ErrNotFound
+	}
+	if len(s.columns) > 0 && !contains(s.columns, deletedField) {
+		delete(doc, deletedField)
+	}
+	return doc, nil
 }
 
 func (s *Session) Insert(data JSONDoc) (JSONDoc, error) {
 	if s.tableName == "" {
 		return JSONDoc{}, fmt.Errorf("need to set a tablename")
 	}
-	return s.orm.dialectDB.Create(s.tableName, data)
+	var newDoc JSONDoc
+	s.stamp(data, true)
+	err := s.hooked(func(s *Session) error {
+		err := s.orm.hooks.run(s, beforeInsert, data)
+		if err != nil {
+			return err
+		}
+		newDoc, err = s.orm.dialectDB.Create(s.tableName, data)
+		if err != nil {
+			return err
+		}
+		return s.orm.hooks.run(s, afterInsert, newDoc)
+	}, beforeInsert, afterInsert)
+	return newDoc, err
 }
 
+// InsertStruct - insert the struct pointed by i. With insert hooks or
+// timestamps the struct goes through Insert as a document and is filled back
+// from the stored one.
 func (s *Session) InsertStruct(i interface{}) error {
 	if s.tableName == "" {
 		return fmt.Errorf("need to set a tablename")
 	}
-	return s.orm.dialectDB.CreateInterface(s.tableName, i)
+	if !s.stamped() && !s.orm.hooks.has(s.tableName, beforeInsert, afterInsert) {
+		return s.orm.dialectDB.CreateInterface(s.tableName, i)
+	}
+	data, err := encodeStruct(i)
+	if err != nil {
+		return err
+	}
+	newDoc, err := s.Insert(data)
+	if err != nil {
+		return err
+	}
+	return decodeDocs([]JSONDoc{newDoc}, i)
 }
 
 func (s *Session) Update(data JSONDoc) error {
 	if s.tableName == "" {
 		return fmt.Errorf("need to set a tablename")
 	}
-	return s.orm.dialectDB.Update(s.tableName, data)
+	s.stamp(data, false)
+	return s.hooked(func(s *Session) error {
+		err := s.orm.hooks.run(s, beforeUpdate, data)
+		if err != nil {
+			return err
+		}
+		err = s.orm.dialectDB.Update(s.tableName, data)
+		if err != nil {
+			return err
+		}
+		return s.orm.hooks.run(s, afterUpdate, data)
+	}, beforeUpdate, afterUpdate)
 }
 
+// DeleteByID - delete the record, soft delete tables only set its _deleted timestamp
 func (s *Session) DeleteByID(id string) error {
-	return s.orm.dialectDB.Delete(s.tableName, id)
+	if s.tableName == "" {
+		return fmt.Errorf("need to set a tablename")
+	}
+	soft := s.softDelete() && !s.purging
+	if !soft && !s.orm.hooks.has(s.tableName, beforeDelete, afterDelete) {
+		return s.orm.dialectDB.Delete(s.tableName, id)
+	}
+	return s.hooked(func(s *Session) error {
+		doc, err := s.orm.dialectDB.GetById(s.tableName, id)
+		if err != nil {
+			return err
+		}
+		if soft && doc[deletedField] != nil {
+			return ErrNotFound
+		}
+		err = s.orm.hooks.run(s, beforeDelete, doc)
+		if err != nil {
+			return err
+		}
+		err = s.remove(doc, id, soft)
+		if err != nil {
+			return err
+		}
+		return s.orm.hooks.run(s, afterDelete, doc)
+	}, beforeDelete, afterDelete)
 
 }
 
+// DeleteByWhere - delete the records matching Where, with delete hooks or
+// soft delete each record is read first
 func (s *Session) DeleteByWhere() error {
-	if s.where == "" {
+	if err := s.check(); err != nil {
+		return err
+	}
+	if !s.filtered() {
 		return fmt.Errorf("need where clause")
 	}
-	return s.orm.dialectDB.Delete(s.tableName, s.where)
+	soft := s.softDelete() && !s.purging
+	if !soft && !s.orm.hooks.has(s.tableName, beforeDelete, afterDelete) {
+		return s.orm.dialectDB.DeleteByWhere(s.tableName, s.query())
+	}
+	fn := func(s *Session) error {
+		q := s.query()
+		q.Order, q.Columns = "", nil
+		list, err := s.orm.dialectDB.GetManyByQuery(s.tableName, q)
+		if err != nil {
+			return err
+		}
+		for _, doc := range list {
+			err = s.orm.hooks.run(s, beforeDelete, doc)
+			if err != nil {
+				return err
+			}
+		}
+		if soft {
+			pk := s.orm.primaryKey(s.tableName)
+			for _, doc := range list {
+				err = s.remove(doc, fmt.Sprint(doc[pk]), true)
+				if err != nil {
+					return err
+				}
+			}
+		} else {
+			err = s.orm.dialectDB.DeleteByWhere(s.tableName, q)
+			if err != nil {
+				return err
+			}
+		}
+		for _, doc := range list {
+			err = s.orm.hooks.run(s, afterDelete, doc)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if soft {
+		return s.atomic(fn)
+	}
+	return s.hooked(fn, beforeDelete, afterDelete)
 
 }
 
-func (s *Session) Count() (int, error) {
+// remove - delete the record, or mark it deleted
+func (s *Session) remove(doc JSONDoc, id string, soft bool) error {
+	if !soft {
+		return s.orm.dialectDB.Delete(s.tableName, id)
+	}
+	doc[deletedField] = time.Now()
+	return s.orm.dialectDB.Update(s.tableName, doc)
+}
+
+// Restore - undo the soft delete of the record
+func (s *Session) Restore(id string) error {
 	if s.tableName == "" {
-		return 0, fmt.Errorf("need to set a tablename")
+		return fmt.Errorf("need to set a tablename")
+	}
+	if !s.softDelete() {
+		return fmt.Errorf("Table %s has no soft delete", s.tableName)
+	}
+	doc, err := s.orm.dialectDB.GetById(s.tableName, id)
+	if err != nil {
+		return err
+	}
+	if doc[deletedField] == nil {
+		return nil
+	}
+	doc[deletedField] = nil
+	return s.Update(doc)
+}
+
+// Purge - remove the record even from a soft delete table
+func (s *Session) Purge(id string) error {
+	purge := *s
+	purge.purging = true
+	return purge.DeleteByID(id)
+}
+
+func (s *Session) Count() (int, error) {
+	if err := s.check(); err != nil {
+		return 0, err
+	}
+	if s.scoped() {
+		return s.orm.dialectDB.CountByWhere(s.tableName, s.query())
 	}
 	i, err := s.orm.dialectDB.Count(s.tableName)
 	if err != nil {
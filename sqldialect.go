@@ -0,0 +1,1215 @@
+package gorgo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/spf13/cast"
+)
+
+// sqlDialect - Dialect implementation shared by the database/sql backends.
+// MySQLDialect, PostgresDialect and SQLiteDialect embed it and fill the syntax
+// fields in InitDB.
+type sqlDialect struct {
+	DB      *sql.DB
+	ShowSQL bool
+	Model   *model
+	Config  ConfigDB
+
+	// quote - quote an identifier
+	quote func(string) string
+	// placeholder - bind parameter format used in the generated sql
+	placeholder sq.PlaceholderFormat
+	// like - operator used by GetAllBySearch
+	like string
+	// returning - Create reads the generated id with RETURNING instead of LastInsertId
+	returning bool
+	// jsonColumn - when set, tables missing from the model are stored as
+	// (id serialType, jsonColumn jsonType) and jsonField extracts a
+	// document field from it
+	jsonColumn string
+	jsonType   string
+	jsonField  func(column string, field string) string
+	serialType string
+	// jsonNumber - number read from a jsonField expression, compared with
+	// numeric values and accumulated, nil when jsonField keeps the json types
+	jsonNumber func(expr string) string
+	// jsonOrder - expression sorting a document field by its json value, nil
+	// to sort by jsonField
+	jsonOrder func(column string, field string) string
+	// columnType - column type of a model field type
+	columnType func(fieldType string) string
+	// columnsQuery - name and type of the columns of the table given as parameter
+	columnsQuery string
+	// indexesQuery - names of the indexes of the table given as parameter
+	indexesQuery string
+	// alterType - statement changing the type of a column, nil when the
+	// database can not do it
+	alterType func(table string, column string, columnType string) string
+	// upsert - clause of an insert updating the columns of the row having
+	// the same keys and incrementing its counters, names are quoted
+	upsert func(table string, keys []string, columns []string, counters []string) string
+	// firstInsertID - LastInsertId of a multi-row insert is the id of its
+	// first row, not of its last one
+	firstInsertID bool
+
+	createdMutex sync.Mutex
+	created      map[string]bool
+	// owner - dialect tracking the created tables of a WithContext copy
+	owner *sqlDialect
+
+	// tx - open transaction of a dialect returned by Begin
+	tx *sql.Tx
+	// ctx - context of a dialect returned by WithContext
+	ctx context.Context
+}
+
+// sqlConn - methods shared by *sql.DB and *sql.Tx
+type sqlConn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// context - context of the statements, Background when none was given
+func (d *sqlDialect) context() context.Context {
+	if d.ctx != nil {
+		return d.ctx
+	}
+	return context.Background()
+}
+
+// conn - the open transaction, or the database pool
+func (d *sqlDialect) conn() sqlConn {
+	if d.tx != nil {
+		return d.tx
+	}
+	return d.DB
+}
+
+// bind - set dst as a copy of the dialect sharing its pool, transaction,
+// context and created tables
+func (d *sqlDialect) bind(dst *sqlDialect) {
+	dst.DB, dst.ShowSQL, dst.Model, dst.Config = d.DB, d.ShowSQL, d.Model, d.Config
+	dst.quote, dst.placeholder, dst.like, dst.returning = d.quote, d.placeholder, d.like, d.returning
+	dst.jsonColumn, dst.jsonType, dst.jsonField, dst.serialType = d.jsonColumn, d.jsonType, d.jsonField, d.serialType
+	dst.columnType, dst.columnsQuery, dst.indexesQuery, dst.alterType = d.columnType, d.columnsQuery, d.indexesQuery, d.alterType
+	dst.jsonNumber, dst.jsonOrder = d.jsonNumber, d.jsonOrder
+	dst.upsert, dst.firstInsertID = d.upsert, d.firstInsertID
+	dst.tx, dst.ctx = d.tx, d.ctx
+	dst.owner = d.tables()
+}
+
+// tables - dialect tracking the created tables
+func (d *sqlDialect) tables() *sqlDialect {
+	if d.owner != nil {
+		return d.owner
+	}
+	return d
+}
+
+// withContext - set dst as a copy of the dialect running its statements with ctx
+func (d *sqlDialect) withContext(dst *sqlDialect, ctx context.Context) {
+	d.bind(dst)
+	dst.ctx = ctx
+}
+
+// begin - set txd as a copy of the dialect running its statements in a new
+// transaction. Tables created inside it are tracked apart, it may roll back.
+func (d *sqlDialect) begin(txd *sqlDialect) error {
+	if d.tx != nil {
+		return fmt.Errorf("Transaction already started")
+	}
+	tx, err := d.DB.BeginTx(d.context(), nil)
+	if err != nil {
+		return err
+	}
+	d.bind(txd)
+	txd.owner = nil
+	txd.tx = tx
+	return nil
+}
+
+//Commit  - commit the transaction opened by Begin
+func (d *sqlDialect) Commit() error {
+	if d.tx == nil {
+		return fmt.Errorf("No transaction to commit")
+	}
+	d.logSQL("COMMIT", nil)
+	return d.tx.Commit()
+}
+
+//Rollback  - discard the transaction opened by Begin
+func (d *sqlDialect) Rollback() error {
+	if d.tx == nil {
+		return fmt.Errorf("No transaction to rollback")
+	}
+	d.logSQL("ROLLBACK", nil)
+	return d.tx.Rollback()
+}
+
+// open - load the model and open the database pool
+func (d *sqlDialect) open(driver string, dsn string, config ConfigDB) error {
+	mod, err := loadModel(config, func(reloaded *model) {
+		d.Model = reloaded
+	})
+	if err != nil {
+		return err
+	}
+	d.Model = mod
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return err
+	}
+	db.SetMaxIdleConns(config.MaxIdle)
+	db.SetMaxOpenConns(config.MaxOpen)
+	d.DB = db
+	d.ShowSQL = config.ShowSQL
+	d.Config = config
+	return nil
+}
+
+//CloseDB  - close database
+func (d *sqlDialect) CloseDB() error {
+	if d.tx != nil {
+		return fmt.Errorf("Could not close the database inside a transaction")
+	}
+	return d.DB.Close()
+}
+
+func (d *sqlDialect) dbModel() *model {
+	return d.Model
+}
+
+// pk - primary key column of the table, "id" unless the model declares an
+// autoincrement field
+func (d *sqlDialect) pk(tableName string) string {
+	return d.Model.primaryKey(tableName, "id")
+}
+
+// schemaless - true when the table is kept as a json document column
+func (d *sqlDialect) schemaless(tableName string) bool {
+	if d.jsonColumn == "" {
+		return false
+	}
+	_, ok := d.Model.Tables[tableName]
+	return !ok
+}
+
+// strictFields - true for the tables of the model, their columns are those of
+// the model, the schemaless rows keep any field
+func (d *sqlDialect) strictFields(tableName string) bool {
+	return !d.schemaless(tableName)
+}
+
+// column - sql expression reading a document field from the table
+func (d *sqlDialect) column(tableName string, field string) string {
+	if d.jsonPath(tableName, field) {
+		return d.jsonField(d.quote(d.jsonColumn), field)
+	}
+	return d.quote(field)
+}
+
+// jsonPath - true when the field is read from the json column
+func (d *sqlDialect) jsonPath(tableName string, field string) bool {
+	return d.schemaless(tableName) && field != d.pk(tableName)
+}
+
+// valueColumn - sql expression of a field compared with value, a json field
+// is read as a number for the numeric values
+func (d *sqlDialect) valueColumn(tableName string, field string, value interface{}) string {
+	if numericValue(value) {
+		return d.numberColumn(tableName, field)
+	}
+	return d.column(tableName, field)
+}
+
+// numberColumn - sql expression of a field accumulated as a number
+func (d *sqlDialect) numberColumn(tableName string, field string) string {
+	if d.jsonNumber != nil && d.jsonPath(tableName, field) {
+		return d.jsonNumber(d.column(tableName, field))
+	}
+	return d.column(tableName, field)
+}
+
+// orderColumn - sql expression sorting the records by a field
+func (d *sqlDialect) orderColumn(tableName string, field string) string {
+	if d.jsonOrder != nil && d.jsonPath(tableName, field) {
+		return d.jsonOrder(d.quote(d.jsonColumn), field)
+	}
+	return d.column(tableName, field)
+}
+
+// numericValue - true for a number or a non empty list of numbers
+func numericValue(value interface{}) bool {
+	list, ok := value.([]interface{})
+	if !ok {
+		return isNumeric(value)
+	}
+	for _, v := range list {
+		if !isNumeric(v) {
+			return false
+		}
+	}
+	return len(list) > 0
+}
+
+func (d *sqlDialect) builder() sq.StatementBuilderType {
+	return sq.StatementBuilder.PlaceholderFormat(d.placeholder)
+}
+
+func (d *sqlDialect) selectFrom(tableName string, columns ...string) sq.SelectBuilder {
+	if len(columns) == 0 {
+		columns = []string{"*"}
+	}
+	return d.builder().Select(columns...).From(d.quote(tableName))
+}
+
+func (d *sqlDialect) logSQL(stmt string, args []interface{}) {
+	if d.ShowSQL == true {
+		log.Println("SQL=", stmt, args)
+	}
+}
+
+func (d *sqlDialect) rows(builder sq.SelectBuilder) ([]JSONDoc, error) {
+	stmt, args, err := builder.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	d.logSQL(stmt, args)
+
+	rows, err := d.conn().QueryContext(d.context(), stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanDocs(rows)
+}
+
+// query - run the select, schemaless rows are flattened and projected to columns
+func (d *sqlDialect) query(tableName string, builder sq.SelectBuilder, columns []string) ([]JSONDoc, error) {
+	list, err := d.rows(builder)
+	if err != nil {
+		return nil, err
+	}
+	if d.schemaless(tableName) {
+		for i, row := range list {
+			list[i] = projectDoc(d.fromRow(row), columns, d.pk(tableName))
+		}
+	}
+	return list, nil
+}
+
+func (d *sqlDialect) queryOne(tableName string, builder sq.SelectBuilder, columns []string) (JSONDoc, error) {
+	list, err := d.query(tableName, builder.Limit(1), columns)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return list[0], nil
+}
+
+func (d *sqlDialect) exec(stmt string, args []interface{}) (sql.Result, error) {
+	d.logSQL(stmt, args)
+	return d.conn().ExecContext(d.context(), stmt, args...)
+}
+
+// execStatement - run a raw statement, for the sql migration files
+func (d *sqlDialect) execStatement(stmt string) error {
+	_, err := d.exec(stmt, nil)
+	return err
+}
+
+func (d *sqlDialect) count(builder sq.SelectBuilder) (int, error) {
+	stmt, args, err := builder.ToSql()
+	if err != nil {
+		return 0, err
+	}
+	d.logSQL(stmt, args)
+
+	var i int
+	err = d.conn().QueryRowContext(d.context(), stmt, args...).Scan(&i)
+	return i, err
+}
+
+// toRow - columns and values to store for a document
+func (d *sqlDialect) toRow(tableName string, data JSONDoc) ([]string, []interface{}, error) {
+	pk := d.pk(tableName)
+	var columns []string
+	var values []interface{}
+	if d.schemaless(tableName) {
+		doc := JSONDoc{}
+		for k, v := range data {
+			if k != pk {
+				doc[k] = v
+			}
+		}
+		encoded, err := json.Marshal(doc)
+		if err != nil {
+			return nil, nil, err
+		}
+		if data[pk] != nil {
+			columns = append(columns, d.quote(pk))
+			values = append(values, data[pk])
+		}
+		return append(columns, d.quote(d.jsonColumn)), append(values, string(encoded)), nil
+	}
+
+	for _, k := range sortMap(data) {
+		if k == pk && data[k] == nil {
+			continue
+		}
+		columns = append(columns, d.quote(k))
+		values = append(values, sqlValue(data[k]))
+	}
+	return columns, values, nil
+}
+
+// fromRow - flatten the json column of a schemaless row into the document
+func (d *sqlDialect) fromRow(row JSONDoc) JSONDoc {
+	doc := JSONDoc{}
+	switch v := row[d.jsonColumn].(type) {
+	case map[string]interface{}:
+		for k, value := range v {
+			doc[k] = value
+		}
+	case string:
+		json.Unmarshal([]byte(v), &doc)
+	}
+	for k, value := range row {
+		if k != d.jsonColumn {
+			doc[k] = value
+		}
+	}
+	return doc
+}
+
+// ensureTable - create the table of a schemaless collection on first use
+func (d *sqlDialect) ensureTable(tableName string) error {
+	if !d.schemaless(tableName) {
+		return nil
+	}
+	t := d.tables()
+	t.createdMutex.Lock()
+	defer t.createdMutex.Unlock()
+	if t.created[tableName] {
+		return nil
+	}
+
+	stmt := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s %s PRIMARY KEY, %s %s NOT NULL)",
+		d.quote(tableName), d.quote(d.pk(tableName)), d.serialType, d.quote(d.jsonColumn), d.jsonType)
+	_, err := d.exec(stmt, nil)
+	if err != nil {
+		return err
+	}
+	if t.created == nil {
+		t.created = make(map[string]bool)
+	}
+	t.created[tableName] = true
+	return nil
+}
+
+func (d *sqlDialect) Create(tableName string, data JSONDoc) (JSONDoc, error) {
+	err := validateFields(tableName, data, d.Model, d.Config.Validations)
+	if err != nil {
+		return data, err
+	}
+	err = d.ensureTable(tableName)
+	if err != nil {
+		return data, err
+	}
+
+	pk := d.pk(tableName)
+	columns, values, err := d.toRow(tableName, data)
+	if err != nil {
+		return data, err
+	}
+	builder := d.builder().Insert(d.quote(tableName)).Columns(columns...).Values(values...)
+
+	if d.returning {
+		stmt, args, err := builder.Suffix("RETURNING " + d.quote(pk)).ToSql()
+		if err != nil {
+			return data, err
+		}
+		d.logSQL(stmt, args)
+		var id interface{}
+		err = d.conn().QueryRowContext(d.context(), stmt, args...).Scan(&id)
+		if err != nil {
+			return data, err
+		}
+		data[pk] = id
+		return data, nil
+	}
+
+	stmt, args, err := builder.ToSql()
+	if err != nil {
+		return data, err
+	}
+	res, err := d.exec(stmt, args)
+	if err != nil {
+		return data, err
+	}
+	if data[pk] == nil {
+		lastID, err := res.LastInsertId()
+		if err != nil {
+			return data, err
+		}
+		data[pk] = lastID
+	}
+	return data, nil
+}
+
+// maxInsertParams - bound parameters of a multi-row insert, the sqlite limit
+const maxInsertParams = 999
+
+//CreateMany  - insert the documents with multi-row INSERT statements in a
+//transaction. The generated ids are read with RETURNING, or derived from
+//LastInsertId when none of the documents of a statement carries its id.
+func (d *sqlDialect) CreateMany(tableName string, docs []JSONDoc) error {
+	err := validateRows(tableName, docs, d.Model, d.Config.Validations)
+	if err != nil {
+		return err
+	}
+	if d.tx == nil {
+		txd := &sqlDialect{}
+		err := d.begin(txd)
+		if err != nil {
+			return err
+		}
+		err = txd.CreateMany(tableName, docs)
+		if err != nil {
+			txd.Rollback()
+			return err
+		}
+		return txd.Commit()
+	}
+	err = d.ensureTable(tableName)
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(docs); {
+		columns, values, err := d.toRow(tableName, docs[start])
+		if err != nil {
+			return err
+		}
+		builder := d.builder().Insert(d.quote(tableName)).Columns(columns...).Values(values...)
+		end := start + 1
+		for ; end < len(docs) && (end-start+1)*len(columns) <= maxInsertParams; end++ {
+			rowColumns, values, err := d.toRow(tableName, docs[end])
+			if err != nil {
+				return err
+			}
+			if strings.Join(rowColumns, ",") != strings.Join(columns, ",") {
+				break
+			}
+			builder = builder.Values(values...)
+		}
+		err = d.insertRows(tableName, builder, docs[start:end])
+		if err != nil {
+			return fmt.Errorf("Insert of rows %d to %d: %v", start, end-1, err)
+		}
+		start = end
+	}
+	return nil
+}
+
+// insertRows - run the insert of docs and set their generated ids
+func (d *sqlDialect) insertRows(tableName string, builder sq.InsertBuilder, docs []JSONDoc) error {
+	pk := d.pk(tableName)
+	if d.returning {
+		stmt, args, err := builder.Suffix("RETURNING " + d.quote(pk)).ToSql()
+		if err != nil {
+			return err
+		}
+		d.logSQL(stmt, args)
+		rows, err := d.conn().QueryContext(d.context(), stmt, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for i := 0; rows.Next() && i < len(docs); i++ {
+			var id interface{}
+			err = rows.Scan(&id)
+			if err != nil {
+				return err
+			}
+			docs[i][pk] = id
+		}
+		return rows.Err()
+	}
+
+	stmt, args, err := builder.ToSql()
+	if err != nil {
+		return err
+	}
+	res, err := d.exec(stmt, args)
+	if err != nil {
+		return err
+	}
+	for _, doc := range docs {
+		if doc[pk] != nil {
+			return nil
+		}
+	}
+	lastID, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	if !d.firstInsertID {
+		lastID -= int64(len(docs) - 1)
+	}
+	for i, doc := range docs {
+		doc[pk] = lastID + int64(i)
+	}
+	return nil
+}
+
+//Upsert  - insert the row, or update the columns of doc in the row having the
+//same keys, with one INSERT statement. The keys need a unique index, mysql
+//uses any of the unique indexes of the table. Schemaless rows are read and
+//written back in a transaction.
+func (d *sqlDialect) Upsert(tableName string, doc JSONDoc, keys []string, onInsert JSONDoc) (JSONDoc, error) {
+	if d.schemaless(tableName) {
+		return d.upsertDoc(tableName, doc, keys, onInsert)
+	}
+	row := copyDoc(doc)
+	for k, v := range onInsert {
+		row[k] = v
+	}
+	err := validateChanged(tableName, row, d.Model, d.Config.Validations)
+	if err != nil {
+		return nil, err
+	}
+
+	columns, values, err := d.toRow(tableName, row)
+	if err != nil {
+		return nil, err
+	}
+	var updates []string
+	for _, k := range sortMap(doc) {
+		updates = append(updates, d.quote(k))
+	}
+	var counters []string
+	if d.Model.versioned(tableName) {
+		counters = append(counters, d.quote(versionField))
+	}
+	clause := d.upsert(d.quote(tableName), quoteAll(d.quote, keys), updates, counters)
+	stmt, args, err := d.builder().Insert(d.quote(tableName)).Columns(columns...).Values(values...).Suffix(clause).ToSql()
+	if err != nil {
+		return nil, err
+	}
+	_, err = d.exec(stmt, args)
+	if err != nil {
+		return nil, err
+	}
+
+	var conds []Cond
+	for _, key := range keys {
+		conds = append(conds, Eq(key, doc[key]))
+	}
+	return d.GetOneByQuery(tableName, Query{Cond: And(conds...)})
+}
+
+// upsertDoc - upsert of a schemaless row, read and written back in a transaction
+func (d *sqlDialect) upsertDoc(tableName string, doc JSONDoc, keys []string, onInsert JSONDoc) (JSONDoc, error) {
+	if d.tx == nil {
+		txd := &sqlDialect{}
+		err := d.begin(txd)
+		if err != nil {
+			return nil, err
+		}
+		stored, err := txd.upsertDoc(tableName, doc, keys, onInsert)
+		if err != nil {
+			txd.Rollback()
+			return nil, err
+		}
+		return stored, txd.Commit()
+	}
+	return upsertDoc(d, tableName, doc, keys, onInsert, func(doc JSONDoc, insert bool) (JSONDoc, error) {
+		if insert {
+			return d.Create(tableName, doc)
+		}
+		return doc, d.Update(tableName, doc)
+	})
+}
+
+// quoteAll - the names quoted with quote
+func quoteAll(quote func(string) string, names []string) []string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = quote(name)
+	}
+	return quoted
+}
+
+// onConflict - upsert clause of sqlite and postgres, the assigned columns are
+// not qualified and the stored row is read through the table name
+func onConflict(table string, keys []string, columns []string, counters []string) string {
+	var sets []string
+	for _, col := range columns {
+		sets = append(sets, col+" = excluded."+col)
+	}
+	for _, col := range counters {
+		sets = append(sets, col+" = COALESCE("+table+"."+col+", 0) + 1")
+	}
+	return "ON CONFLICT (" + strings.Join(keys, ", ") + ") DO UPDATE SET " + strings.Join(sets, ", ")
+}
+
+// CreateInterface - insert a struct through Create, writing the generated id back into it
+func (d *sqlDialect) CreateInterface(tableName string, i interface{}) error {
+	data, err := encodeStruct(i)
+	if err != nil {
+		return err
+	}
+	pk := d.pk(tableName)
+	if v, ok := data[pk]; ok && (v == nil || isZeroValue(reflect.ValueOf(v))) {
+		delete(data, pk)
+	}
+	newDoc, err := d.Create(tableName, data)
+	if err != nil {
+		return err
+	}
+	return setStructField(i, pk, newDoc[pk])
+}
+
+// selectColumns - quoted columns plus the primary key. Schemaless tables
+// select the whole row and are projected after scanning.
+func (d *sqlDialect) selectColumns(tableName string, columns []string) []string {
+	if len(columns) == 0 || d.schemaless(tableName) {
+		return nil
+	}
+	pk := d.pk(tableName)
+	selected := []string{d.quote(pk)}
+	for _, c := range columns {
+		if c != pk {
+			selected = append(selected, d.quote(c))
+		}
+	}
+	return selected
+}
+
+func (d *sqlDialect) orderBy(tableName string, sorted string) []string {
+	return sqlOrder(sorted, func(field string) string {
+		return d.orderColumn(tableName, field)
+	})
+}
+
+// selectQuery - SELECT of the query columns with its ORDER BY
+func (d *sqlDialect) selectQuery(tableName string, q Query) sq.SelectBuilder {
+	builder := d.selectFrom(tableName, d.selectColumns(tableName, q.Columns)...)
+	if q.Order != "" {
+		builder = builder.OrderBy(d.orderBy(tableName, q.Order)...)
+	}
+	return builder
+}
+
+// where - WHERE expression of the native query and the condition, nil for none
+func (d *sqlDialect) where(tableName string, q Query) sq.Sqlizer {
+	var parts sq.And
+	if q.Where != "" {
+		parts = append(parts, sq.Expr(q.Where, q.Params...))
+	}
+	if !q.Cond.empty() {
+		parts = append(parts, q.Cond.sqlizer(func(field string, value interface{}) string {
+			return d.valueColumn(tableName, field, value)
+		}))
+	}
+	switch len(parts) {
+	case 0:
+		return nil
+	case 1:
+		return parts[0]
+	}
+	return parts
+}
+
+func (d *sqlDialect) GetById(tableName string, id string, columns ...string) (JSONDoc, error) {
+	builder := d.selectQuery(tableName, Query{Columns: columns}).Where(sq.Eq{d.quote(d.pk(tableName)): id})
+	return d.queryOne(tableName, builder, columns)
+}
+
+func (d *sqlDialect) GetOneByQuery(tableName string, q Query) (JSONDoc, error) {
+	return d.queryOne(tableName, d.selectQuery(tableName, q).Where(d.where(tableName, q)), q.Columns)
+}
+
+func (d *sqlDialect) GetManyByQuery(tableName string, q Query) ([]JSONDoc, error) {
+	builder := paged(d.selectQuery(tableName, q).Where(d.where(tableName, q)), q.Offset, q.Limit)
+	return d.query(tableName, builder, q.Columns)
+}
+
+//Stream  - rows of the query read one by one from the result set, which
+//holds a connection, or the transaction, until Close
+func (d *sqlDialect) Stream(tableName string, q Query) (*Rows, error) {
+	builder := paged(d.selectQuery(tableName, q).Where(d.where(tableName, q)), q.Offset, q.Limit)
+	stmt, args, err := builder.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	d.logSQL(stmt, args)
+	rows, err := d.conn().QueryContext(d.context(), stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	columns, err := rows.ColumnTypes()
+	if err != nil {
+		rows.Close()
+		return nil, err
+	}
+	next := func() (JSONDoc, error) {
+		if !rows.Next() {
+			return nil, rows.Err()
+		}
+		doc, err := scanDoc(rows, columns)
+		if err != nil || !d.schemaless(tableName) {
+			return doc, err
+		}
+		return projectDoc(d.fromRow(doc), q.Columns, d.pk(tableName)), nil
+	}
+	return newRows(next, rows.Close), nil
+}
+
+func (d *sqlDialect) GetAll(tableName string, offset int, limit int, q Query) ([]JSONDoc, error) {
+	return d.query(tableName, paged(d.selectQuery(tableName, q), offset, limit), q.Columns)
+}
+
+// paged - the select skipping offset rows and returning at most limit, 0 for all
+func paged(builder sq.SelectBuilder, offset int, limit int) sq.SelectBuilder {
+	if limit > 0 {
+		builder = builder.Limit(uint64(limit))
+	}
+	if offset > 0 {
+		builder = builder.Offset(uint64(offset))
+	}
+	return builder
+}
+
+func (d *sqlDialect) GetAllBySearch(tableName string, text string, field string, page int, qtd int, q Query) ([]JSONDoc, error) {
+	builder := d.selectQuery(tableName, q).
+		Where(d.column(tableName, field)+" "+d.like+" ?", "%"+text+"%")
+	if qtd > 0 {
+		builder = builder.Limit(uint64(qtd))
+		if page > 1 {
+			builder = builder.Offset(uint64((page - 1) * qtd))
+		}
+	}
+	return d.query(tableName, builder, q.Columns)
+}
+
+func (d *sqlDialect) Update(tableName string, data JSONDoc) (err error) {
+	pk := d.pk(tableName)
+	if data[pk] == nil {
+		return fmt.Errorf("Field %s could not be null", pk)
+	}
+
+	err = validateFields(tableName, data, d.Model, d.Config.Validations)
+	if err != nil {
+		return err
+	}
+
+	var where sq.Sqlizer = sq.Eq{d.quote(pk): data[pk]}
+	versioned := d.Model.versioned(tableName)
+	if versioned {
+		// the row is only updated when it still has the version data was read with
+		version := cast.ToInt64(data[versionField])
+		if version == 0 {
+			where = sq.And{where, sq.Or{sq.Eq{d.quote(versionField): 0}, sq.Eq{d.quote(versionField): nil}}}
+		} else {
+			where = sq.And{where, sq.Eq{d.quote(versionField): version}}
+		}
+		data[versionField] = version + 1
+		defer func() {
+			if err != nil {
+				data[versionField] = version
+			}
+		}()
+	}
+
+	columns, values, err := d.toRow(tableName, data)
+	if err != nil {
+		return err
+	}
+	builder := d.builder().Update(d.quote(tableName))
+	for i, col := range columns {
+		if col != d.quote(pk) {
+			builder = builder.Set(col, values[i])
+		}
+	}
+	stmt, args, err := builder.Where(where).ToSql()
+	if err != nil {
+		return err
+	}
+
+	res, err := d.exec(stmt, args)
+	if err != nil {
+		return err
+	}
+	rowCnt, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowCnt == 0 {
+		if versioned {
+			n, cerr := d.count(d.selectFrom(tableName, "COUNT(*)").Where(sq.Eq{d.quote(pk): data[pk]}))
+			if cerr == nil && n > 0 {
+				return ErrStaleObject
+			}
+		}
+		return fmt.Errorf("Item id[%v] not found", data[pk])
+	}
+	return nil
+}
+
+//Patch  - change some fields of the row in one UPDATE, ex: SET stock =
+//COALESCE(stock, 0) + ?. Push needs a document, it is only available on the
+//schemaless tables, whose rows are read and written back in a transaction.
+func (d *sqlDialect) Patch(tableName string, id string, ops []FieldOp) error {
+	err := validateChanged(tableName, changedFields(ops), d.Model, d.Config.Validations)
+	if err != nil {
+		return err
+	}
+	if d.schemaless(tableName) {
+		return d.patchDoc(tableName, id, ops)
+	}
+
+	pk := d.pk(tableName)
+	builder := d.builder().Update(d.quote(tableName))
+	for _, op := range ops {
+		col := d.quote(op.field)
+		switch op.op {
+		case "$set":
+			builder = builder.Set(col, sqlValue(op.value))
+		case "$unset":
+			builder = builder.Set(col, nil)
+		case "$inc":
+			builder = builder.Set(col, sq.Expr("COALESCE("+col+", 0) + ?", op.value))
+		default:
+			return fmt.Errorf("Operation %s is not supported on the sql column %s", op.op, op.field)
+		}
+	}
+	if d.Model.versioned(tableName) {
+		col := d.quote(versionField)
+		builder = builder.Set(col, sq.Expr("COALESCE("+col+", 0) + 1"))
+	}
+	stmt, args, err := builder.Where(sq.Eq{d.quote(pk): id}).ToSql()
+	if err != nil {
+		return err
+	}
+	res, err := d.exec(stmt, args)
+	if err != nil {
+		return err
+	}
+	rowCnt, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowCnt == 0 {
+		return fmt.Errorf("Item id[%v] not found", id)
+	}
+	return nil
+}
+
+// patchDoc - read, change and write back a schemaless row in a transaction
+func (d *sqlDialect) patchDoc(tableName string, id string, ops []FieldOp) error {
+	if d.tx == nil {
+		txd := &sqlDialect{}
+		err := d.begin(txd)
+		if err != nil {
+			return err
+		}
+		err = txd.patchDoc(tableName, id, ops)
+		if err != nil {
+			txd.Rollback()
+			return err
+		}
+		return txd.Commit()
+	}
+	doc, err := d.GetById(tableName, id)
+	if err != nil {
+		return err
+	}
+	err = applyOps(doc, ops)
+	if err != nil {
+		return err
+	}
+	return d.Update(tableName, doc)
+}
+
+func (d *sqlDialect) Delete(tableName string, id string) error {
+	stmt, args, err := d.builder().Delete(d.quote(tableName)).Where(sq.Eq{d.quote(d.pk(tableName)): id}).ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = d.exec(stmt, args)
+	return err
+}
+
+func (d *sqlDialect) DeleteByWhere(tableName string, q Query) error {
+	where := d.where(tableName, q)
+	if where == nil {
+		return fmt.Errorf("need where clause")
+	}
+	stmt, args, err := d.builder().Delete(d.quote(tableName)).Where(where).ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = d.exec(stmt, args)
+	return err
+}
+
+func (d *sqlDialect) Count(tableName string) (int, error) {
+	return d.count(d.selectFrom(tableName, "COUNT(*)"))
+}
+
+func (d *sqlDialect) CountByWhere(tableName string, q Query) (int, error) {
+	return d.count(d.selectFrom(tableName, "COUNT(*)").Where(d.where(tableName, q)))
+}
+
+// GetByGroup - run a mongo style $group stage as a GROUP BY query, ex:
+// {"$group": {"_id": "$city", "total": {"$sum": "$amount"}, "count": {"$sum": 1}}}
+func (d *sqlDialect) GetByGroup(tableName string, query map[string]interface{}) (JSONDoc, error) {
+	columns, groupBy, err := groupToSQL(query, func(field string) string {
+		return d.column(tableName, field)
+	}, func(field string) string {
+		return d.numberColumn(tableName, field)
+	}, d.quote)
+	if err != nil {
+		return nil, err
+	}
+	builder := d.selectFrom(tableName, columns...)
+	if len(groupBy) > 0 {
+		builder = builder.GroupBy(groupBy...)
+	}
+	list, err := d.rows(builder.Limit(1))
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return list[0], nil
+}
+
+//Aggregate  - the groups with a GROUP BY query, selected from as a
+//subquery to filter them by Having and sort them by their names
+func (d *sqlDialect) Aggregate(tableName string, q Query, agg Aggregation) ([]JSONDoc, error) {
+	var columns, groupBy []string
+	for _, field := range agg.GroupBy {
+		col := d.column(tableName, field)
+		columns = append(columns, col+" AS "+d.quote(groupName(field)))
+		groupBy = append(groupBy, col)
+	}
+	for _, acc := range agg.Accumulators {
+		expr := "COUNT(*)"
+		if acc.op != "$count" {
+			expr = strings.ToUpper(strings.TrimPrefix(acc.op, "$")) + "(" + d.numberColumn(tableName, acc.field) + ")"
+		}
+		columns = append(columns, expr+" AS "+d.quote(acc.alias))
+	}
+	inner := sq.Select(columns...).From(d.quote(tableName)).Where(d.where(tableName, q))
+	if len(groupBy) > 0 {
+		inner = inner.GroupBy(groupBy...)
+	}
+
+	builder := d.builder().Select("*").FromSelect(inner, "g")
+	if !agg.Having.empty() {
+		builder = builder.Where(agg.Having.sqlizer(func(field string, _ interface{}) string {
+			return d.quote(field)
+		}))
+	}
+	if q.Order != "" {
+		builder = builder.OrderBy(sqlOrder(q.Order, d.quote)...)
+	}
+	return d.rows(paged(builder, q.Offset, q.Limit))
+}
+
+// quoteANSI - quote an identifier with double quotes
+func quoteANSI(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// quoteLiteral - quote a string literal
+func quoteLiteral(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+// sqlOrder - convert "-field,other" or "field desc, other asc" into ORDER BY terms
+func sqlOrder(sorted string, column func(string) string) []string {
+	var terms []string
+	for _, t := range parseOrder(sorted) {
+		direction := "ASC"
+		if t.desc {
+			direction = "DESC"
+		}
+		terms = append(terms, column(t.field)+" "+direction)
+	}
+	return terms
+}
+
+// groupToSQL - select columns and GROUP BY terms for a mongo style $group
+// stage, number reads the accumulated fields as numbers
+func groupToSQL(query map[string]interface{}, column func(string) string, number func(string) string, quote func(string) string) ([]string, []string, error) {
+	stage := query
+	if g, ok := query["$group"].(map[string]interface{}); ok {
+		stage = g
+	}
+
+	var columns, groupBy []string
+	switch id := stage["_id"].(type) {
+	case nil:
+	case string:
+		col := column(strings.TrimPrefix(id, "$"))
+		columns = append(columns, col+" AS "+quote("_id"))
+		groupBy = append(groupBy, col)
+	case map[string]interface{}:
+		for _, k := range sortMap(id) {
+			col := column(strings.TrimPrefix(cast.ToString(id[k]), "$"))
+			columns = append(columns, col+" AS "+quote(k))
+			groupBy = append(groupBy, col)
+		}
+	default:
+		return nil, nil, fmt.Errorf("Invalid group _id %v", id)
+	}
+
+	for _, alias := range sortMap(stage) {
+		if alias == "_id" {
+			continue
+		}
+		acc, ok := stage[alias].(map[string]interface{})
+		if !ok || len(acc) != 1 {
+			return nil, nil, fmt.Errorf("Invalid accumulator for %s", alias)
+		}
+		for op, arg := range acc {
+			expr := "*"
+			if field, ok := arg.(string); ok {
+				expr = number(strings.TrimPrefix(field, "$"))
+			}
+			switch op {
+			case "$sum":
+				if expr == "*" {
+					columns = append(columns, fmt.Sprintf("COUNT(*)*%v AS %s", cast.ToFloat64(arg), quote(alias)))
+				} else {
+					columns = append(columns, "SUM("+expr+") AS "+quote(alias))
+				}
+			case "$avg":
+				columns = append(columns, "AVG("+expr+") AS "+quote(alias))
+			case "$min":
+				columns = append(columns, "MIN("+expr+") AS "+quote(alias))
+			case "$max":
+				columns = append(columns, "MAX("+expr+") AS "+quote(alias))
+			case "$count":
+				columns = append(columns, "COUNT(*) AS "+quote(alias))
+			default:
+				return nil, nil, fmt.Errorf("Accumulator %s not supported", op)
+			}
+		}
+	}
+	return columns, groupBy, nil
+}
+
+// sqlValue - convert a JSONDoc value to something database/sql can store,
+// maps, slices and structs are stored as json
+func sqlValue(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	switch v.(type) {
+	case []byte, time.Time:
+		return v
+	}
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return nil
+		}
+		return string(encoded)
+	}
+	return v
+}
+
+// scanDocs - read all rows into JSONDocs, typing each value by its column type
+func scanDocs(rows *sql.Rows) ([]JSONDoc, error) {
+	columns, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []JSONDoc{}
+	for rows.Next() {
+		doc, err := scanDoc(rows, columns)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, doc)
+	}
+	return result, rows.Err()
+}
+
+// scanDoc - document of the current row
+func scanDoc(rows *sql.Rows, columns []*sql.ColumnType) (JSONDoc, error) {
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	err := rows.Scan(pointers...)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := JSONDoc{}
+	for i, col := range columns {
+		doc[col.Name()] = columnValue(col.DatabaseTypeName(), values[i])
+	}
+	return doc, nil
+}
+
+// columnValue - convert a raw column value to the go type matching the column type
+func columnValue(dbType string, v interface{}) interface{} {
+	raw, ok := v.([]byte)
+	if !ok {
+		return v
+	}
+	str := string(raw)
+	dbType = strings.ToUpper(dbType)
+	switch {
+	case strings.Contains(dbType, "INT") || dbType == "YEAR":
+		if i, err := strconv.ParseInt(str, 10, 64); err == nil {
+			return i
+		}
+	case dbType == "DECIMAL" || dbType == "NUMERIC" || dbType == "DOUBLE" ||
+		dbType == "REAL" || strings.HasPrefix(dbType, "FLOAT"):
+		if f, err := strconv.ParseFloat(str, 64); err == nil {
+			return f
+		}
+	case dbType == "BOOL" || dbType == "BOOLEAN":
+		if b, err := strconv.ParseBool(str); err == nil {
+			return b
+		}
+	case dbType == "DATETIME" || dbType == "DATE" || strings.HasPrefix(dbType, "TIMESTAMP"):
+		for _, layout := range []string{"2006-01-02 15:04:05.999999999Z07:00", "2006-01-02 15:04:05.999999", "2006-01-02"} {
+			if t, err := time.Parse(layout, str); err == nil {
+				return t
+			}
+		}
+	case dbType == "JSON" || dbType == "JSONB":
+		var decoded interface{}
+		if err := json.Unmarshal(raw, &decoded); err == nil {
+			return decoded
+		}
+	}
+	return str
+}
@@ -0,0 +1,247 @@
+package gorgo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cast"
+)
+
+const sqliteTestModel = `{
+  "schema" : "sqlite-test",
+  "tables" :[
+    {
+      "name": "user",
+      "fields": [
+        "_id,bigint,autoincrement",
+        "name,string,minlen=2,maxlen=15,required",
+        "email, string, unique , validation=isEmail",
+        "age, int"
+      ]
+    }
+  ]
+}`
+
+func newSQLiteTest(t *testing.T) (*ORM, func()) {
+	dir, err := ioutil.TempDir("", "gorgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	modelFile := filepath.Join(dir, "model.json")
+	err = ioutil.WriteFile(modelFile, []byte(sqliteTestModel), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := ConfigDB{}
+	config.ModelFile = modelFile
+	config.Type = "sqlite"
+	config.SyncDB = SyncCreate
+	config.Server = filepath.Join(dir, "sqlitetest.db")
+
+	DB, err := NewOrm(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return DB, func() {
+		DB.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestSQLiteDialect_Create(t *testing.T) {
+	DB, done := newSQLiteTest(t)
+	defer done()
+
+	userRet, err := DB.Table("user").Insert(JSONDoc{"name": "john Doe", "email": "jd@test.com", "age": 25})
+	if err != nil {
+		t.Fatal("DB Create Error : ", err)
+	}
+	_, err = DB.Table("user").Insert(JSONDoc{"name": "jane Doe", "email": "jane@test.com", "age": 31})
+	if err != nil {
+		t.Fatal("DB Create Error : ", err)
+	}
+	_, err = DB.Table("user").Insert(JSONDoc{"name": "other", "email": "jd@test.com", "age": 40})
+	if err == nil {
+		t.Fatal("expected unique index violation")
+	}
+
+	id := cast.ToString(userRet["_id"])
+	user, err := DB.Table("user").GetByID(id)
+	if err != nil {
+		t.Fatal("DB GetByID Error : ", err)
+	}
+	if user["email"] != "jd@test.com" || user["age"] != int64(25) {
+		t.Fatalf("unexpected user %v", user)
+	}
+
+	list, err := DB.Table("user").Where("age > ?", 30).Get()
+	if err != nil {
+		t.Fatal("DB GetManyByQuery Error : ", err)
+	}
+	if len(list) != 1 || list[0]["name"] != "jane Doe" {
+		t.Fatalf("unexpected result %v", list)
+	}
+
+	user["name"] = "upd john doe"
+	err = DB.Table("user").Update(user)
+	if err != nil {
+		t.Fatal("DB Update Error : ", err)
+	}
+	list, err = DB.dialectDB.GetAllBySearch("user", "UPD", "name", 1, 10, Query{})
+	if err != nil || len(list) != 1 {
+		t.Fatal("DB GetAllBySearch Error : ", list, err)
+	}
+
+	err = DB.Table("user").DeleteByID(id)
+	if err != nil {
+		t.Fatal("DB Delete Error : ", err)
+	}
+	i, err := DB.Table("user").Count()
+	if err != nil || i != 1 {
+		t.Fatal("DB Count Error : ", i, err)
+	}
+}
+
+func TestSQLiteDialect_Schemaless(t *testing.T) {
+	DB, done := newSQLiteTest(t)
+	defer done()
+
+	for _, kind := range []string{"login", "logout", "login"} {
+		_, err := DB.Table("event").Insert(JSONDoc{"kind": kind, "user": "john"})
+		if err != nil {
+			t.Fatal("DB Create Error : ", err)
+		}
+	}
+
+	list, err := DB.Table("event").Where("json_extract(data, '$.kind') = ?", "login").Get()
+	if err != nil {
+		t.Fatal("DB GetManyByQuery Error : ", err)
+	}
+	if len(list) != 2 || list[0]["user"] != "john" {
+		t.Fatalf("unexpected result %v", list)
+	}
+
+	group, err := DB.dialectDB.GetByGroup("event", map[string]interface{}{
+		"_id":   nil,
+		"count": map[string]interface{}{"$sum": 1},
+	})
+	if err != nil {
+		t.Fatal("DB GetByGroup Error : ", err)
+	}
+	if cast.ToInt(group["count"]) != 3 {
+		t.Fatalf("unexpected group %v", group)
+	}
+}
+
+func TestSQLiteDialect_OrderBySelect(t *testing.T) {
+	DB, done := newSQLiteTest(t)
+	defer done()
+
+	for i, name := range []string{"john Doe", "jane Doe", "mary Doe"} {
+		_, err := DB.Table("user").Insert(JSONDoc{"name": name, "email": name[:4] + "@test.com", "age": 20 + i})
+		if err != nil {
+			t.Fatal("DB Create Error : ", err)
+		}
+	}
+
+	list, err := DB.Table("user").Where("age > ?", 20).OrderBy("age desc").Select("name").Get()
+	if err != nil {
+		t.Fatal("DB GetManyByQuery Error : ", err)
+	}
+	if len(list) != 2 || list[0]["name"] != "mary Doe" || list[1]["name"] != "jane Doe" {
+		t.Fatalf("unexpected order %v", list)
+	}
+	if _, ok := list[0]["email"]; ok || list[0]["_id"] == nil {
+		t.Fatalf("expected only _id and name, got %v", list[0])
+	}
+
+	list, err = DB.Table("user").OrderBy("name").Get()
+	if err != nil || len(list) != 3 || list[0]["name"] != "jane Doe" {
+		t.Fatal("DB GetAll Error : ", list, err)
+	}
+}
+
+func TestSQLiteDialect_SyncDB(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	modelFile := filepath.Join(dir, "model.json")
+	err = ioutil.WriteFile(modelFile, []byte(sqliteTestModel), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := ConfigDB{}
+	config.ModelFile = modelFile
+	config.Type = "sqlite"
+	config.SyncDB = SyncCreate
+	config.Server = filepath.Join(dir, "sync.db")
+	DB, err := NewOrm(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = DB.Table("user").Insert(JSONDoc{"name": "john Doe", "email": "john@test.com", "age": 30})
+	if err != nil {
+		t.Fatal("DB Create Error : ", err)
+	}
+	DB.Close()
+
+	// age is dropped, city and its index added, name becomes an int
+	model := strings.Replace(sqliteTestModel, `"age, int"`, `"city, string, index"`, 1)
+	model = strings.Replace(model, `"name,string,minlen=2,maxlen=15,required"`, `"name,int"`, 1)
+	err = ioutil.WriteFile(modelFile, []byte(model), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending := func(DB *ORM) []string {
+		changes, err := DB.SyncSchema(SyncDryRun)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var list []string
+		for _, change := range changes {
+			if change.Applied {
+				t.Fatal("dry run applied ", change)
+			}
+			list = append(list, change.Action+" "+change.Column)
+		}
+		return list
+	}
+
+	config.SyncDB = "everything"
+	_, err = NewOrm(config)
+	if err == nil {
+		t.Fatal("expected unknown SyncDB mode error")
+	}
+
+	for _, c := range []struct {
+		mode    string
+		pending []string
+	}{
+		{"", []string{"alter column name", "add column city", "create index city", "drop column age"}},
+		{SyncDryRun, []string{"alter column name", "add column city", "create index city", "drop column age"}},
+		{SyncCreate, []string{"alter column name", "drop column age"}},
+		{SyncFull, []string{"alter column name"}},
+	} {
+		config.SyncDB = c.mode
+		DB, err = NewOrm(config)
+		if err != nil {
+			t.Fatal(c.mode, " NewOrm Error : ", err)
+		}
+		list := pending(DB)
+		if !reflect.DeepEqual(list, c.pending) {
+			t.Fatal(c.mode, " expected pending ", c.pending, " got ", list)
+		}
+		i, err := DB.Table("user").Count()
+		if err != nil || i != 1 {
+			t.Fatal(c.mode, " Count Error : ", i, err)
+		}
+		DB.Close()
+	}
+}
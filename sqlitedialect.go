@@ -0,0 +1,79 @@
+package gorgo
+
+import (
+	"context"
+
+	sq "github.com/Masterminds/squirrel"
+	_ "modernc.org/sqlite" // Package for sqlite driver, pure go
+)
+
+//SQLiteDialect - dialect for an embedded sqlite file. Tables declared in the
+//model are created as real tables, any other table keeps its documents in a
+//json text column.
+type SQLiteDialect struct {
+	sqlDialect
+}
+
+func init() {
+	RegisterDialect("sqlite", func() Dialect { return &SQLiteDialect{} })
+}
+
+// syntax - set the sqlite flavor of the shared sql dialect
+func (s *SQLiteDialect) syntax() {
+	s.quote = quoteANSI
+	s.placeholder = sq.Question
+	s.like = "LIKE"
+	s.jsonColumn = "data"
+	s.jsonType = "TEXT"
+	s.serialType = "INTEGER"
+	s.jsonField = func(column string, field string) string {
+		return "json_extract(" + column + ", " + quoteLiteral("$."+field) + ")"
+	}
+	s.columnType = sqliteType
+	s.columnsQuery = "SELECT name, type FROM pragma_table_info(?)"
+	s.indexesQuery = "SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = ?"
+	s.upsert = onConflict
+}
+
+//InitDB  - initialize database, config.Server is the database file
+func (s *SQLiteDialect) InitDB(config ConfigDB) error {
+	s.syntax()
+
+	err := s.open("sqlite", config.Server, config)
+	if err != nil {
+		return err
+	}
+	// sqlite serializes writers, a single connection avoids "database is locked"
+	s.DB.SetMaxOpenConns(1)
+	return nil
+}
+
+//WithContext  - copy of the dialect running its statements with ctx
+func (s *SQLiteDialect) WithContext(ctx context.Context) Dialect {
+	dst := &SQLiteDialect{}
+	s.withContext(&dst.sqlDialect, ctx)
+	return dst
+}
+
+//Begin  - open a transaction, the returned dialect runs every call inside it
+func (s *SQLiteDialect) Begin() (TxDialect, error) {
+	txd := &SQLiteDialect{}
+	err := s.begin(&txd.sqlDialect)
+	if err != nil {
+		return nil, err
+	}
+	return txd, nil
+}
+
+// sqliteType - column type for a model field type
+func sqliteType(fieldType string) string {
+	switch fieldType {
+	case "int", "bigint":
+		return "INTEGER"
+	case "float", "double":
+		return "REAL"
+	case "date":
+		return "DATETIME"
+	}
+	return "TEXT"
+}
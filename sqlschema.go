@@ -0,0 +1,175 @@
+package gorgo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SyncSchema - create the missing tables, columns and indexes of the model
+// tables, change the column types and drop the columns removed from the
+// model as mode allows. Tables missing from the model are left alone.
+func (d *sqlDialect) SyncSchema(mode string) ([]SchemaChange, error) {
+	var changes []SchemaChange
+	for _, name := range d.Model.tableNames() {
+		synced, err := d.syncTable(name, mode)
+		changes = append(changes, synced...)
+		if err != nil {
+			return changes, err
+		}
+	}
+	return changes, nil
+}
+
+// syncTable - reconcile one model table with the database
+func (d *sqlDialect) syncTable(name string, mode string) ([]SchemaChange, error) {
+	planned, err := d.planTable(name)
+	if err != nil {
+		return nil, err
+	}
+	var changes []SchemaChange
+	for _, change := range planned {
+		if change.Applied && change.appliesIn(mode) {
+			_, err = d.exec(change.Detail, nil)
+			if err != nil {
+				return changes, err
+			}
+		} else {
+			change.Applied = false
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// planTable - differences between the model table and the database, Applied
+// is set on the changes the database is able to make
+func (d *sqlDialect) planTable(name string) ([]SchemaChange, error) {
+	t := d.Model.Tables[name]
+	pk := d.pk(name)
+	existing, err := d.schemaRows(d.columnsQuery, name)
+	if err != nil {
+		return nil, err
+	}
+	indexes, err := d.schemaRows(d.indexesQuery, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []SchemaChange
+	if len(existing) == 0 {
+		columns := []string{d.quote(pk) + " " + d.serialType + " PRIMARY KEY"}
+		for _, f := range t.Fields {
+			if f.Name != pk {
+				columns = append(columns, d.quote(f.column())+" "+d.columnType(f.Type))
+			}
+		}
+		changes = append(changes, SchemaChange{
+			Table:   name,
+			Action:  "create table",
+			Detail:  fmt.Sprintf("CREATE TABLE %s (%s)", d.quote(name), strings.Join(columns, ", ")),
+			Applied: true,
+		})
+	}
+
+	declared := map[string]bool{pk: true}
+	for _, f := range t.Fields {
+		column := f.column()
+		declared[column] = true
+		if f.Name == pk || len(existing) == 0 {
+			continue
+		}
+		want := d.columnType(f.Type)
+		have, ok := existing[column]
+		switch {
+		case !ok:
+			changes = append(changes, SchemaChange{
+				Table:   name,
+				Column:  column,
+				Action:  "add column",
+				Detail:  fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", d.quote(name), d.quote(column), want),
+				Applied: true,
+			})
+		case !sameColumnType(have, want):
+			change := SchemaChange{Table: name, Column: column, Action: "alter column"}
+			if d.alterType != nil {
+				change.Detail = d.alterType(d.quote(name), d.quote(column), want)
+				change.Applied = true
+			} else {
+				change.Detail = fmt.Sprintf("type %s is %s in the database, it can not be changed", want, have)
+			}
+			changes = append(changes, change)
+		}
+	}
+
+	for _, f := range t.Fields {
+		if f.Name == pk || !f.Unique && !f.Index {
+			continue
+		}
+		index, unique := "ix_"+name+"_"+f.column(), ""
+		if f.Unique {
+			index, unique = "ux_"+name+"_"+f.column(), "UNIQUE "
+		}
+		if _, ok := indexes[index]; ok {
+			continue
+		}
+		changes = append(changes, SchemaChange{
+			Table:   name,
+			Column:  f.column(),
+			Action:  "create index",
+			Detail:  fmt.Sprintf("CREATE %sINDEX %s ON %s (%s)", unique, d.quote(index), d.quote(name), d.quote(f.column())),
+			Applied: true,
+		})
+	}
+
+	for _, column := range sortMap(existing) {
+		if declared[column] {
+			continue
+		}
+		changes = append(changes, SchemaChange{
+			Table:   name,
+			Column:  column,
+			Action:  "drop column",
+			Detail:  fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", d.quote(name), d.quote(column)),
+			Applied: true,
+		})
+	}
+	return changes, nil
+}
+
+// schemaRows - first column of the rows of a catalog query on the table,
+// mapped to the second one when it has two
+func (d *sqlDialect) schemaRows(stmt string, tableName string) (JSONDoc, error) {
+	args := []interface{}{tableName}
+	d.logSQL(stmt, args)
+	rows, err := d.conn().QueryContext(d.context(), stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	result := JSONDoc{}
+	for rows.Next() {
+		var name, value string
+		dest := []interface{}{&name}
+		if len(cols) > 1 {
+			dest = append(dest, &value)
+		}
+		err = rows.Scan(dest...)
+		if err != nil {
+			return nil, err
+		}
+		result[name] = value
+	}
+	return result, rows.Err()
+}
+
+// sameColumnType - true when the database type have is the model type want,
+// ignoring case and the sizes or modifiers the database appends
+func sameColumnType(have interface{}, want string) bool {
+	h, w := strings.ToLower(fmt.Sprint(have)), strings.ToLower(want)
+	return h == w || strings.HasPrefix(h, w+"(") || strings.HasPrefix(h, w+" ")
+}
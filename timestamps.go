@@ -0,0 +1,95 @@
+package gorgo
+
+import (
+	"context"
+	"time"
+)
+
+// Timestamps and audit fields. Insert sets _created and _updated, Update sets
+// _updated, and with an actor, given by Session.As or by a context of
+// WithActor, _created_by and _updated_by. A model table may rename them or
+// turn them off with its timestamps option:
+//
+//	{"name": "user", "timestamps": {"created": "created_at", "updatedBy": ""}, ...}
+//
+// The sql tables of the model only get the fields the option declares, or
+// without it the default ones they have a column for, the others, like the
+// schemaless sql tables, get the default ones.
+
+type actorKey struct{}
+
+// WithActor - context whose sessions record actor, ex: the id of the logged
+// user, in the _created_by and _updated_by fields
+func WithActor(ctx context.Context, actor interface{}) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// As - record actor in the _created_by and _updated_by fields of the writes
+func (s *Session) As(actor interface{}) *Session {
+	s.actor = actor
+	return s
+}
+
+// stamps - fields stamped on the records of the session table
+func (s *Session) stamps() stamps {
+	var fields []*field
+	if md, ok := s.orm.dialectDB.(modelDialect); ok {
+		t, ok := md.dbModel().Tables[s.tableName]
+		if ok && t.Stamps != nil {
+			return *t.Stamps
+		}
+		fields = t.Fields
+	}
+	if sd, ok := s.orm.dialectDB.(strictDialect); ok && sd.strictFields(s.tableName) {
+		return defaultStamps.declared(fields)
+	}
+	return defaultStamps
+}
+
+// declared - the stamps whose field is one of fields
+func (st stamps) declared(fields []*field) stamps {
+	has := func(name string) string {
+		for _, f := range fields {
+			if f.column() == name {
+				return name
+			}
+		}
+		return ""
+	}
+	return stamps{Created: has(st.Created), Updated: has(st.Updated), CreatedBy: has(st.CreatedBy), UpdatedBy: has(st.UpdatedBy)}
+}
+
+// versioned - true when the table uses optimistic locking
+func (s *Session) versioned() bool {
+	md, ok := s.orm.dialectDB.(modelDialect)
+	return ok && md.dbModel().versioned(s.tableName)
+}
+
+// stamped - true when the table has a field to stamp
+func (s *Session) stamped() bool {
+	return s.stamps() != stamps{} || s.versioned()
+}
+
+// stamp - set the timestamps and audit fields of an insert, or of an update
+func (s *Session) stamp(data JSONDoc, insert bool) {
+	st := s.stamps()
+	now := time.Now()
+	actor := s.actor
+	if actor == nil {
+		actor = s.orm.actor
+	}
+	set := func(name string, value interface{}) {
+		if name != "" && value != nil {
+			data[name] = value
+		}
+	}
+	if insert {
+		set(st.Created, now)
+		set(st.CreatedBy, actor)
+		if s.versioned() && data[versionField] == nil {
+			data[versionField] = 1
+		}
+	}
+	set(st.Updated, now)
+	set(st.UpdatedBy, actor)
+}
@@ -0,0 +1,90 @@
+package gorgo
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSession_Timestamps(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	modelFile := filepath.Join(dir, "model.json")
+	model := strings.Replace(sqliteTestModel, `"name": "user",`,
+		`"name": "user", "timestamps": {"created": "created_at", "updated": "updated_at"},`, 1)
+	model = strings.Replace(model, `"tables" :[`,
+		`"tables" :[{"name": "audit", "fields": ["id,bigint,autoincrement", "kind,string", "_created,datetime"]},`, 1)
+	err = ioutil.WriteFile(modelFile, []byte(model), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dialect := range []string{"memory", "localdb", "sqlite"} {
+		config := ConfigDB{}
+		config.ModelFile = modelFile
+		config.Type = dialect
+		config.SyncDB = SyncCreate
+		config.Server = filepath.Join(dir, dialect+".db")
+		DB, err := NewOrm(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		user, err := DB.Table("user").As("admin").Insert(JSONDoc{"name": "john Doe", "email": "john@test.com"})
+		if err != nil {
+			t.Fatal(dialect, " DB Create Error : ", err)
+		}
+		id := fmt.Sprint(user[DB.primaryKey("user")])
+		stored, err := DB.Table("user").GetByID(id)
+		if err != nil || stored["created_at"] == nil || stored["updated_at"] == nil || stored["_created_by"] != "admin" {
+			t.Fatal(dialect, " insert not stamped : ", stored, err)
+		}
+		if _, ok := stored["_created"]; ok {
+			t.Fatal(dialect, " default field stamped on a table renaming it : ", stored)
+		}
+
+		ctx := WithActor(context.Background(), "editor")
+		stored["age"] = 30
+		err = DB.Table("user").WithContext(ctx).Update(stored)
+		if err != nil {
+			t.Fatal(dialect, " DB Update Error : ", err)
+		}
+		updated, err := DB.Table("user").GetByID(id)
+		if err != nil || updated["_updated_by"] != "editor" || updated["_created_by"] != "admin" || updated["created_at"] == nil {
+			t.Fatal(dialect, " update not stamped : ", updated, err)
+		}
+
+		// tables outside the model get the default fields
+		doc, err := DB.Table("event").Insert(JSONDoc{"kind": "login"})
+		if err != nil {
+			t.Fatal(dialect, " DB Create Error : ", err)
+		}
+		if doc["_created"] == nil || doc["_updated"] == nil {
+			t.Fatal(dialect, " default stamps missing : ", doc)
+		}
+		stored, err = DB.Table("event").GetByID(fmt.Sprint(doc[DB.primaryKey("event")]))
+		if err != nil || stored["_created"] == nil {
+			t.Fatal(dialect, " default stamps not stored : ", stored, err)
+		}
+
+		// model tables without the option get the default fields they have
+		doc, err = DB.Table("audit").Insert(JSONDoc{"kind": "login"})
+		if err != nil {
+			t.Fatal(dialect, " DB Create Error : ", err)
+		}
+		if doc["_created"] == nil {
+			t.Fatal(dialect, " declared default stamp missing : ", doc)
+		}
+		if _, ok := doc["_updated"]; ok == (dialect == "sqlite") {
+			t.Fatal(dialect, " unexpected default stamps : ", doc)
+		}
+		DB.Close()
+	}
+}
@@ -228,3 +228,18 @@ func validateFields(collection string, data JSONDoc, mod *model, funcs FuncMap)
 
 	return nil
 }
+
+// validateChanged - validate only the fields present in changes, for partial updates
+func validateChanged(collection string, changes JSONDoc, mod *model, funcs FuncMap) error {
+	t, ok := mod.Tables[collection]
+	if !ok {
+		return nil
+	}
+	changed := table{Name: t.Name}
+	for _, f := range t.Fields {
+		if _, ok := changes[f.Name]; ok {
+			changed.Fields = append(changed.Fields, f)
+		}
+	}
+	return validateFields(collection, changes, &model{Tables: map[string]table{collection: changed}}, funcs)
+}